@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"path/filepath"
@@ -19,12 +20,64 @@ var (
 	notionGoCookbookStartPage = "7495260a1daa46118858ad2e049e77e6"
 )
 
+// rootPageIDs are pages from which we start crawling, set with -roots.
+// The first one is the content of index.html
+var rootPageIDs = []string{notionWebsiteStartPage}
+
+// parseNotionPageID returns normalized id of a page given its id or url
+func parseNotionPageID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	id := normalizeID(s)
+	if len(id) != 32 {
+		id = extractNotionIDFromURL(s)
+	}
+	if len(id) != 32 {
+		return "", fmt.Errorf("'%s' is not a valid notion page id or url", s)
+	}
+	return id, nil
+}
+
+// parseRootPageIDs parses comma-separated list of page ids or notion urls
+func parseRootPageIDs(s string) ([]string, error) {
+	var res []string
+	seen := map[string]bool{}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		id, err := parseNotionPageID(v)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no page ids in '%s'", s)
+	}
+	return res, nil
+}
+
+func isRootPageID(id string) bool {
+	id = normalizeID(id)
+	for _, rootID := range rootPageIDs {
+		if id == rootID {
+			return true
+		}
+	}
+	return false
+}
+
 // for Article.Status
 const (
 	statusNormal       = iota // show on main page
 	statusNotImportant        // linked from archive page, but not main page
 	statusHidden              // not linked from any page but accessible via url
 	statusDeleted             // not shown at all
+	statusDraft               // not published yet, only generated with -include-drafts
 )
 
 // URLPath describes
@@ -52,12 +105,43 @@ type Article struct {
 	CollectionURL  string
 	Status         int
 	Description    string
+	Author         string
 	Paths          []URLPath
 	Metadata       []*MetaValue
 	urlOverride    string
+	// true if ID was set with "id:" metadata and should be used as permalink
+	hasCustomID bool
+	// id of the page that contains this page, "" for top-level pages
+	ParentID string
+	// chronological neighbors among blog articles, nil for the oldest
+	// and the newest article
+	PrevArticle *Article
+	NextArticle *Article
+	// blog articles with the most tags in common, see findRelated
+	RelatedArticles []*Article
+	// url of additional css file, set with "css:" metadata
+	CSSURL string
+	// content of <meta name="robots">, set with "robots:" metadata
+	Robots string
+	// name of template in "templates" directory used instead of
+	// article.tmpl.html, set with "template:" metadata
+	Template string
+	// old urls of the page, set with "redirectfrom:" metadata. We write
+	// html files that redirect from them to the article
+	RedirectFrom []string
+	// icon of notion page shown next to the title. It's either an emoji
+	// or url of an image
+	IconEmoji string
+	IconURL   string
 
 	UpdatedAgeStr string
 	Images        []ImageMapping
+	ReadingTime   time.Duration
+
+	// true if the page has equations which need KaTeX
+	HasMath bool
+	// true if the page has code blocks which need copy.js
+	HasCode bool
 
 	// if true, this belongs to blog i.e. will be present in atom.xml
 	// and listed in blog section
@@ -70,6 +154,9 @@ type Article struct {
 type Articles struct {
 	idToArticle map[string]*Article
 	idToPage    map[string]*notionapi.Page
+	// needed to re-create articles from changed pages in -watch mode
+	idToParentID map[string]string
+	idToRow      map[string]*collectionRow
 	// all downloaded articles
 	articles []*Article
 	// articles that are not hidden
@@ -80,6 +167,28 @@ type Articles struct {
 	blogNotHidden []*Article
 }
 
+// remove removes article that failed to generate
+func (a *Articles) remove(article *Article) {
+	without := func(articles []*Article) []*Article {
+		var res []*Article
+		for _, other := range articles {
+			if other != article {
+				res = append(res, other)
+			}
+		}
+		return res
+	}
+	a.articles = without(a.articles)
+	a.blog = without(a.blog)
+	for id, other := range a.idToArticle {
+		if other == article {
+			delete(a.idToArticle, id)
+		}
+	}
+	a.articlesNotHidden = nil
+	a.blogNotHidden = nil
+}
+
 func (a *Articles) getNotHidden() []*Article {
 	if a.articlesNotHidden == nil {
 		var arr []*Article
@@ -111,7 +220,24 @@ func (a *Article) URL() string {
 	if a.urlOverride != "" {
 		return a.urlOverride
 	}
-	return "/article/" + a.ID + "/" + urlify(a.Title) + ".html"
+	return "/article/" + a.Slug() + "/"
+}
+
+// Slug returns unique, readable name of the article used in its url
+// e.g. "microconf-videos-2c3d4e5f". ID set in metadata is used as is
+func (a *Article) Slug() string {
+	if a.hasCustomID {
+		return a.ID
+	}
+	shortID := a.ID
+	if len(shortID) > 8 {
+		shortID = shortID[:8]
+	}
+	slug := slugify(a.Title)
+	if slug == "" {
+		return shortID
+	}
+	return slug + "-" + shortID
 }
 
 // PathAsText returns navigation path as text
@@ -127,19 +253,59 @@ func (a *Article) PathAsText() string {
 func (a *Article) TagsDisplay() template.HTML {
 	arr := make([]string, 0)
 	for _, tag := range a.Tags {
-		// TODO: url-quote the first tag
-		escapedURL := fmt.Sprintf(`<a href="/tag/%s" class="taglink">%s</a>`, tag, tag)
+		escapedURL := fmt.Sprintf(`<a href="%s" class="taglink">%s</a>`, tagURL(tag), template.HTMLEscapeString(tag))
 		arr = append(arr, escapedURL)
 	}
 	s := strings.Join(arr, ", ")
 	return template.HTML(s)
 }
 
+// default for -date-format
+const defaultDateFormat = "Jan 2, 2006"
+
+// PublishedOnDisplay is publishing date formatted with -date-format
+func (a *Article) PublishedOnDisplay() string {
+	layout := flgDateFormat
+	if layout == "" {
+		layout = defaultDateFormat
+	}
+	return a.PublishedOn.Format(layout)
+}
+
+// default for -author
+const defaultAuthor = "Krzysztof Kowalczyk"
+
+// siteAuthor returns author of articles without "author:" metadata
+func siteAuthor() string {
+	if flgAuthor == "" {
+		return defaultAuthor
+	}
+	return flgAuthor
+}
+
+// AuthorURL returns url of the page listing articles by the author
+func (a *Article) AuthorURL() string {
+	return authorURL(a.Author)
+}
+
+// PublishedOnISO is publishing date in RFC 3339 format, for <time datetime="">
+func (a *Article) PublishedOnISO() string {
+	return a.PublishedOn.Format(time.RFC3339)
+}
+
 // PublishedOnShort is a short version of date
 func (a *Article) PublishedOnShort() string {
 	return a.PublishedOn.Format("Jan 2 2006")
 }
 
+// ReadingTimeDisplay returns estimated reading time e.g. "5 min read"
+func (a *Article) ReadingTimeDisplay() string {
+	if a.ReadingTime == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d min read", int(a.ReadingTime/time.Minute))
+}
+
 // IsBlog returns true if this article belongs to a blog
 func (a *Article) IsBlog() bool {
 	return a.inBlog
@@ -153,7 +319,24 @@ func (a *Article) UpdatedAge() int {
 
 // IsHidden returns true if article should not be shown in the index
 func (a *Article) IsHidden() bool {
-	return a.Status == statusHidden || a.Status == statusDeleted || a.Status == statusNotImportant
+	return a.Status == statusHidden || a.Status == statusDeleted || a.Status == statusNotImportant || a.IsDraft() || a.IsScheduled()
+}
+
+// IsScheduled returns true if article's date is in the future. It's not
+// listed anywhere until then, unless -include-future flag is given
+func (a *Article) IsScheduled() bool {
+	return !flgIncludeFuture && a.PublishedOn.After(time.Now())
+}
+
+// IsDraft returns true if article is not yet published
+func (a *Article) IsDraft() bool {
+	return a.Status == statusDraft
+}
+
+// shouldGenerate returns true if we should generate html for the article.
+// Drafts are only generated when -include-drafts flag is given
+func (a *Article) shouldGenerate() bool {
+	return !a.IsDraft() || flgIncludeDrafts
 }
 
 func parseTags(s string) []string {
@@ -190,23 +373,74 @@ func parseStatus(status string) (int, error) {
 		return statusNormal, nil
 	}
 	switch status {
+	case "normal":
+		return statusNormal, nil
 	case "hidden":
 		return statusHidden, nil
 	case "notimportant":
 		return statusNotImportant, nil
 	case "deleted":
 		return statusDeleted, nil
+	case "draft":
+		return statusDraft, nil
 	default:
 		return 0, fmt.Errorf("'%s' is not a valid status", status)
 	}
 }
 
+// statusName is the reverse of parseStatus
+func statusName(status int) string {
+	switch status {
+	case statusNormal:
+		return "normal"
+	case statusNotImportant:
+		return "notimportant"
+	case statusHidden:
+		return "hidden"
+	case statusDeleted:
+		return "deleted"
+	case statusDraft:
+		return "draft"
+	}
+	return ""
+}
+
 func setStatusMust(article *Article, val string) {
 	var err error
 	article.Status, err = parseStatus(val)
 	panicIfErr(err)
 }
 
+// setRobotsMust sets article.Robots from comma-separated list of
+// directives e.g. "noindex, nofollow"
+func setRobotsMust(article *Article, val string) {
+	var directives []string
+	for _, s := range strings.Split(val, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "":
+			continue
+		case "index", "noindex", "follow", "nofollow":
+			directives = append(directives, s)
+		default:
+			panicMsg("'%s' in robots: metadata is not a valid directive (index, noindex, follow, nofollow)", s)
+		}
+	}
+	article.Robots = strings.Join(directives, ", ")
+}
+
+// IsNoIndex returns true if search engines should not index the article
+func (a *Article) IsNoIndex() bool {
+	for _, s := range strings.Split(a.Robots, ", ") {
+		if s == "noindex" {
+			return true
+		}
+	}
+	return false
+}
+
+// collections other than those with hand-made index pages get
+// a generated page at /<collection>/
 func setCollectionMust(article *Article, val string) {
 	collectionURL := ""
 	switch val {
@@ -216,14 +450,124 @@ func setCollectionMust(article *Article, val string) {
 	case "go-windows":
 		// ignore
 		return
+	default:
+		slug := slugify(val)
+		panicIf(slug == "", "'%s' is not a valid collection", val)
+		panicIf(u.DirExists(filepath.Join("www", slug)), "collection '%s' conflicts with directory www/%s", val, slug)
+		collectionURL = "/" + slug + "/"
 	}
-	panicIf(collectionURL == "", "'%s' is not a known collection", val)
 	article.Collection = val
 	article.CollectionURL = collectionURL
+}
 
+// hasCollectionPage returns true if we generate index page for article's collection
+func (a *Article) hasCollectionPage() bool {
+	return strings.HasSuffix(a.CollectionURL, "/")
 }
 
-func setHeaderImageMust(article *Article, val string) {
+// findImageBlock returns image block in the page referenced by val, which
+// can be a block id or a link to a block e.g. https://notion.so/Title-<page id>#<block id>
+func findImageBlock(page *notionapi.Page, val string) *notionapi.Block {
+	if i := strings.LastIndex(val, "#"); i >= 0 {
+		val = val[i+1:]
+	}
+	id := normalizeID(val)
+	if len(id) != 32 {
+		return nil
+	}
+	var found *notionapi.Block
+	var visit func(block *notionapi.Block)
+	visit = func(block *notionapi.Block) {
+		if block == nil || found != nil {
+			return
+		}
+		if block.Type == notionapi.BlockImage && normalizeID(block.ID) == id {
+			found = block
+			return
+		}
+		for _, b := range block.Content {
+			visit(b)
+		}
+	}
+	visit(page.Root)
+	return found
+}
+
+// isNotionFileURL returns true if uri is a file uploaded to notion. Those
+// urls expire so we have to download them
+func isNotionFileURL(uri string) bool {
+	return strings.Contains(uri, "secure.notion-static.com/")
+}
+
+// setLocalHeaderImage downloads header image and makes it part of the article's
+// images. If download fails, uri is used as is
+func setLocalHeaderImage(c *notionapi.Client, article *Article, uri string) {
+	path, err := downloadAndCacheImage(c, uri)
+	if err != nil {
+		logWarn("Warning: downloading header image '%s' of page https://notion.so/%s failed with '%s'\n", uri, normalizeID(article.page.ID), err)
+		warnIfExpiredURL(uri, article.page.ID)
+		article.HeaderImageURL = uri
+		return
+	}
+	relURL := "/img/" + filepath.Base(path)
+	im := ImageMapping{
+		path:        path,
+		relativeURL: relURL,
+	}
+	article.Images = append(article.Images, im)
+	article.HeaderImageURL = netlifyRequestGetFullHost() + relURL
+}
+
+// notionCoverURL returns absolute url of page cover. Notion's built-in
+// covers have urls relative to notion.so e.g. /images/page-cover/gradients_11.jpg
+func notionCoverURL(uri string) string {
+	if strings.HasPrefix(uri, "/") {
+		return "https://www.notion.so" + uri
+	}
+	return uri
+}
+
+// setPageIcon sets emoji or image icon of the page. Images are downloaded
+// and become part of the article's images. If download fails, uri is used as is
+func setPageIcon(c *notionapi.Client, article *Article, icon string) {
+	if !strings.HasPrefix(icon, "https://") && !strings.HasPrefix(icon, "http://") {
+		article.IconEmoji = icon
+		return
+	}
+	path, err := downloadAndCacheImage(c, icon)
+	if err != nil {
+		logWarn("Warning: downloading icon '%s' of page https://notion.so/%s failed with '%s'\n", icon, normalizeID(article.page.ID), err)
+		warnIfExpiredURL(icon, article.page.ID)
+		article.IconURL = icon
+		return
+	}
+	relURL := "/img/" + filepath.Base(path)
+	im := ImageMapping{
+		path:        path,
+		relativeURL: relURL,
+	}
+	article.Images = append(article.Images, im)
+	article.IconURL = relURL
+}
+
+// setHeaderImageMust sets header image from "headerimage:" metadata. val can be:
+// - a reference to an image block in the page
+// - url of a file uploaded to notion
+// - url of an image
+// - path of a file in www directory
+func setHeaderImageMust(c *notionapi.Client, article *Article, val string) {
+	if block := findImageBlock(article.page, val); block != nil {
+		setLocalHeaderImage(c, article, block.Source)
+		return
+	}
+	if isNotionFileURL(val) {
+		setLocalHeaderImage(c, article, val)
+		return
+	}
+	if strings.HasPrefix(val, "https://") || strings.HasPrefix(val, "http://") {
+		article.HeaderImageURL = val
+		return
+	}
 	if val[0] != '/' {
 		val = "/" + val
 	}
@@ -234,7 +578,178 @@ func setHeaderImageMust(article *Article, val string) {
 	article.HeaderImageURL = uri
 }
 
+// directory with css files that articles can use with "css:" metadata
+var wwwCSSDir = filepath.Join("www", "css")
+
+func setCSSMust(article *Article, val string) {
+	name := strings.TrimPrefix(val, "/css/")
+	isValid := name == filepath.Base(name) && strings.HasSuffix(strings.ToLower(name), ".css")
+	panicIf(!isValid, "'%s' in css: metadata is not a name of .css file in %s", val, wwwCSSDir)
+	path := filepath.Join(wwwCSSDir, name)
+	panicIf(!u.FileExists(path), "File '%s' for css: metadata doesn't exist", path)
+	article.CSSURL = "/css/" + name
+}
+
+func setTemplateMust(article *Article, val string) {
+	name := val
+	if !strings.HasSuffix(name, ".tmpl.html") {
+		name += ".tmpl.html"
+	}
+	panicIf(name != filepath.Base(name), "'%s' in template: metadata is not a name of a template in %s", val, tmplDirs[0])
+	article.Template = name
+}
+
+// setRedirectFromMust sets RedirectFrom from comma-separated list of paths
+// e.g. "/article/old-title.html, /old/"
+func setRedirectFromMust(article *Article, val string) {
+	for _, path := range strings.Split(val, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		isValid := strings.HasPrefix(path, "/") && !strings.Contains(path, "..")
+		panicIf(!isValid, "'%s' in redirectfrom: metadata is not a path starting with '/'", path)
+		article.RedirectFrom = append(article.RedirectFrom, path)
+	}
+}
+
+// unknownMeta describes metadata line with a key we don't recognize
+type unknownMeta struct {
+	nBlock int
+	key    string
+	text   string
+}
+
+// metadataError returns an error listing all unknown metadata keys in a page
+// so that they can be fixed at once
+func metadataError(page *notionapi.Page, unknown []*unknownMeta) error {
+	if len(unknown) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, m := range unknown {
+		s := fmt.Sprintf("  block %d: unknown key '%s' in '%s'", m.nBlock, m.key, m.text)
+		lines = append(lines, s)
+	}
+	title := page.Root.Title
+	return fmt.Errorf("notion page with id '%s', '%s' has %d unsupported meta keys:\n%s", normalizeID(page.ID), title, len(unknown), strings.Join(lines, "\n"))
+}
+
+// requiredMetaKeys are metadata keys that every page must have, set
+// with -required-meta flag
+var requiredMetaKeys []string
+
+// metaKeyAliases maps metadata keys to the canonical key used
+// when checking for required keys
+var metaKeyAliases = map[string]string{
+	"createdat":   "date",
+	"publishedon": "date",
+}
+
+func canonicalMetaKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if alias, ok := metaKeyAliases[key]; ok {
+		return alias
+	}
+	return key
+}
+
+// parseRequiredMetaKeys parses comma-separated list of metadata keys
+func parseRequiredMetaKeys(s string) []string {
+	var res []string
+	for _, key := range strings.Split(s, ",") {
+		key = canonicalMetaKey(key)
+		if key != "" {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// missingMetaError returns an error listing required metadata keys
+// that are not present in the page
+func missingMetaError(page *notionapi.Page, foundKeys map[string]bool) error {
+	var missing []string
+	for _, key := range requiredMetaKeys {
+		if !foundKeys[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	title := page.Root.Title
+	return fmt.Errorf("notion page with id '%s', '%s' is missing required meta keys: %s", normalizeID(page.ID), title, strings.Join(missing, ", "))
+}
+
+// setArticleMeta sets a field of the article from metadata key and value.
+// Returns false if key is not a known metadata key
+func setArticleMeta(c *notionapi.Client, article *Article, key string, val string, publishedOnOverwrite *time.Time) bool {
+	var err error
+	switch key {
+	case "tags":
+		article.Tags = parseTags(val)
+		//fmt.Printf("Tags: %v\n", res.Tags)
+	case "id":
+		// empty id means we use notion page id
+		if val != "" {
+			articleSetID(article, val)
+			article.hasCustomID = true
+		}
+		//fmt.Printf("ID: %s\n", res.ID)
+	case "publishedon":
+		// PublishedOn over-writes Date and CreatedAt
+		*publishedOnOverwrite, err = parseDate(val)
+		panicIfErr(err)
+		article.inBlog = true
+	case "date", "createdat":
+		article.PublishedOn, err = parseDate(val)
+		panicIfErr(err)
+		article.inBlog = true
+	case "updatedat":
+		article.UpdatedOn, err = parseDate(val)
+		panicIfErr(err)
+	case "status":
+		setStatusMust(article, val)
+	case "description":
+		article.Description = val
+		//fmt.Printf("Description: %s\n", res.Description)
+	case "author":
+		article.Author = val
+	case "headerimage":
+		setHeaderImageMust(c, article, val)
+	case "collection":
+		setCollectionMust(article, val)
+	case "url":
+		article.urlOverride = val
+	case "css":
+		setCSSMust(article, val)
+	case "robots":
+		setRobotsMust(article, val)
+	case "template":
+		setTemplateMust(article, val)
+	case "redirectfrom":
+		setRedirectFromMust(article, val)
+	default:
+		return false
+	}
+	return true
+}
+
 func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
+	return notionPageToArticleWithProps(c, page, nil)
+}
+
+// notionPageToArticleWithProps is like notionPageToArticle but also sets
+// metadata from props, which are properties of a row in notion collection.
+// Metadata in the page over-writes props
+// metadata keys with free text values that can continue on the next line
+var continuedMetaKeys = map[string]bool{
+	"description": true,
+	"tags":        true,
+}
+
+func notionPageToArticleWithProps(c *notionapi.Client, page *notionapi.Page, props map[string]string) *Article {
 	blocks := page.Root.Content
 	//fmt.Printf("extractMetadata: %s-%s, %d blocks\n", title, id, len(blocks))
 	// metadata blocks are always at the beginning. They are TypeText blocks and
@@ -247,13 +762,37 @@ func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
 		Title: title,
 	}
 	nBlock := 0
-	var err error
-	endLoop := false
+
+	// unrecognized keys might be typos in metadata or just text that looks
+	// like "key: value". We only know it's a typo if a recognized key follows
+	var unknown []*unknownMeta
+	var pendingUnknown []*unknownMeta
+	var pendingBlocks []*notionapi.Block
+	foundKeys := map[string]bool{}
+	// last recognized key and its value, for continuation lines
+	var lastKey, lastVal string
 
 	article.PublishedOn = root.CreatedOn()
 	article.UpdatedOn = root.UpdatedOn()
 	var publishedOnOverwrite time.Time
 
+	// collections can have columns that are not metadata so unknown
+	// keys are not an error
+	var propKeys []string
+	for key := range props {
+		propKeys = append(propKeys, key)
+	}
+	sort.Strings(propKeys)
+	for _, key := range propKeys {
+		val := strings.TrimSpace(props[key])
+		if val == "" {
+			continue
+		}
+		if setArticleMeta(c, article, key, val, &publishedOnOverwrite) {
+			foundKeys[canonicalMetaKey(key)] = true
+		}
+	}
+
 	for len(blocks) > 0 {
 		block := blocks[0]
 		//fmt.Printf("  %d %s '%s'\n", nBlock, block.Type, block.Title)
@@ -287,6 +826,21 @@ func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
 		}
 		//fmt.Printf("  %d %s '%s'\n", nBlock, block.Type, s)
 
+		// an indented line without ':' right after description or tags
+		// continues its value e.g.:
+		// Description: a long description
+		//   that wraps
+		// other lines without ':' are the start of article text
+		isIndented := inline.Text[0] == ' ' || inline.Text[0] == '\t'
+		if lastKey != "" && isIndented && !strings.Contains(s, ":") {
+			lastVal += " " + s
+			setArticleMeta(c, article, lastKey, lastVal, &publishedOnOverwrite)
+			blocks = blocks[1:]
+			nBlock++
+			continue
+		}
+		lastKey = ""
+
 		// parse generic metadata like "@foo: bar" or "@foo bar"
 		if s[0] == '@' {
 			s := s[1:]
@@ -305,6 +859,8 @@ func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
 				value: value,
 			}
 			article.Metadata = append(article.Metadata, meta)
+			unknown = append(unknown, pendingUnknown...)
+			pendingUnknown = nil
 			blocks = blocks[1:]
 			nBlock++
 			continue
@@ -317,54 +873,50 @@ func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
 		}
 		key := strings.ToLower(strings.TrimSpace(parts[0]))
 		val := strings.TrimSpace(parts[1])
-		switch key {
-		case "tags":
-			article.Tags = parseTags(val)
-			//fmt.Printf("Tags: %v\n", res.Tags)
-		case "id":
-			articleSetID(article, val)
-			//fmt.Printf("ID: %s\n", res.ID)
-		case "publishedon":
-			// PublishedOn over-writes Date and CreatedAt
-			publishedOnOverwrite, err = parseDate(val)
-			panicIfErr(err)
-			article.inBlog = true
-		case "date", "createdat":
-			article.PublishedOn, err = parseDate(val)
-			panicIfErr(err)
-			article.inBlog = true
-		case "updatedat":
-			article.UpdatedOn, err = parseDate(val)
-			panicIfErr(err)
-		case "status":
-			setStatusMust(article, val)
-		case "description":
-			article.Description = val
-			//fmt.Printf("Description: %s\n", res.Description)
-		case "headerimage":
-			setHeaderImageMust(article, val)
-		case "collection":
-			setCollectionMust(article, val)
-		case "url":
-			article.urlOverride = val
-		default:
-			// assume that unrecognized meta means this article doesn't have
-			// proper meta tags. It might miss meta-tags that are badly named
-			endLoop = true
-			/*
-				rmCached(page.ID)
-				title := page.Page.Title
-				panicMsg("Unsupported meta '%s' in notion page with id '%s', '%s'", key, normalizeID(page.ID), title)
-			*/
-		}
-		if endLoop {
-			break
+		foundKeys[canonicalMetaKey(key)] = true
+		if !setArticleMeta(c, article, key, val, &publishedOnOverwrite) {
+			if len(pendingUnknown) == 0 {
+				pendingBlocks = blocks
+			}
+			m := &unknownMeta{
+				nBlock: nBlock,
+				key:    key,
+				text:   s,
+			}
+			pendingUnknown = append(pendingUnknown, m)
+			blocks = blocks[1:]
+			nBlock++
+			continue
+		}
+		if continuedMetaKeys[key] {
+			lastKey, lastVal = key, val
 		}
+		unknown = append(unknown, pendingUnknown...)
+		pendingUnknown = nil
 		blocks = blocks[1:]
 		nBlock++
 	}
+	if len(pendingUnknown) > 0 {
+		// not followed by recognized meta so assume it's part of content
+		blocks = pendingBlocks
+	}
 	root.Content = blocks
 
+	if err := metadataError(page, unknown); err != nil {
+		// don't cache the page so that fixed version is re-downloaded
+		rmCached(page.ID)
+		panicIfErr(err)
+	}
+
+	if err := missingMetaError(page, foundKeys); err != nil {
+		if flgStrictMeta {
+			rmCached(page.ID)
+			panicIfErr(err)
+		}
+		// PublishedOn is already set from page's creation time
+		logWarn("Warning: %s\n", err)
+	}
+
 	if !publishedOnOverwrite.IsZero() {
 		article.PublishedOn = publishedOnOverwrite
 	}
@@ -373,6 +925,10 @@ func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
 		article.ID = id
 	}
 
+	if article.Author == "" {
+		article.Author = siteAuthor()
+	}
+
 	if article.Collection != "" {
 		path := URLPath{
 			Name: article.Collection,
@@ -384,16 +940,10 @@ func notionPageToArticle(c *notionapi.Client, page *notionapi.Page) *Article {
 	format := root.FormatPage
 	// set image header from cover page
 	if article.HeaderImageURL == "" && format != nil && format.PageCover != "" {
-		path, err := downloadAndCacheImage(c, format.PageCover)
-		panicIfErr(err)
-		relURL := "/img/" + filepath.Base(path)
-		im := ImageMapping{
-			path:        path,
-			relativeURL: relURL,
-		}
-		article.Images = append(article.Images, im)
-		uri := netlifyRequestGetFullHost() + relURL
-		article.HeaderImageURL = uri
+		setLocalHeaderImage(c, article, notionCoverURL(format.PageCover))
+	}
+	if format != nil && format.PageIcon != "" {
+		setPageIcon(c, article, format.PageIcon)
 	}
 	return article
 }
@@ -404,51 +954,35 @@ func articleSetID(a *Article, v string) {
 	// - blog posts imported from quicknotes have id that are strings
 	// - articles written in notion, have notion string id
 	a.ID = strings.TrimSpace(v)
+	panicIf(strings.ContainsAny(a.ID, "/?# "), "'%s' is not a valid id", v)
 	id, err := strconv.Atoi(a.ID)
 	if err == nil {
 		a.ID = u.EncodeBase64(id)
 	}
 }
 
-func addIDToBlock(block *notionapi.Block, idToBlock map[string]*notionapi.Block) {
-	id := normalizeID(block.ID)
-	idToBlock[id] = block
-	for _, block := range block.Content {
-		if block == nil {
-			continue
-		}
-		addIDToBlock(block, idToBlock)
-	}
-}
-
-func buildArticleNavigation(article *Article, isRootPage func(string) bool, idToBlock map[string]*notionapi.Block) {
+func buildArticleNavigation(article *Article, isRootPage func(string) bool, idToArticle map[string]*Article) {
 	// some already have path (e.g. those that belong to a collection)
 	if len(article.Paths) > 0 {
 		return
 	}
 
-	page := article.page.Root
-	currID := normalizeID(page.ParentID)
-
 	var paths []URLPath
-	for !isRootPage(currID) {
-		block := idToBlock[currID]
-		if block == nil {
+	seen := map[string]bool{article.ID: true}
+	currID := article.ParentID
+	for currID != "" && !isRootPage(currID) {
+		parent := idToArticle[currID]
+		// seen protects against cycles
+		if parent == nil || seen[parent.ID] {
 			break
 		}
-		// parent could be a column
-		if block.Type != notionapi.BlockPage {
-			currID = normalizeID(block.ParentID)
-			continue
-		}
-		title := block.Title
-		uri := "/article/" + normalizeID(block.ID) + "/" + urlify(title)
+		seen[parent.ID] = true
 		path := URLPath{
-			Name: title,
-			URL:  uri,
+			Name: parent.Title,
+			URL:  parent.URL(),
 		}
 		paths = append(paths, path)
-		currID = normalizeID(block.ParentID)
+		currID = parent.ParentID
 	}
 
 	// set in reverse order
@@ -461,38 +995,56 @@ func buildArticleNavigation(article *Article, isRootPage func(string) bool, idTo
 
 // build navigation bread-crumbs for articles
 func buildArticlesNavigation(articles *Articles) {
-	idToBlock := map[string]*notionapi.Block{}
-	for _, a := range articles.articles {
-		page := a.page
-		if page == nil {
-			continue
-		}
-		addIDToBlock(page.Root, idToBlock)
-	}
-
 	isRoot := func(id string) bool {
 		id = normalizeID(id)
 		switch id {
 		case notionBlogsStartPage, notionWebsiteStartPage, notionGoCookbookStartPage:
 			return true
 		}
-		return false
+		return isRootPageID(id)
 	}
 
 	for _, article := range articles.articles {
-		buildArticleNavigation(article, isRoot, idToBlock)
+		buildArticleNavigation(article, isRoot, articles.idToArticle)
 	}
 }
 
 func loadArticles(c *notionapi.Client) *Articles {
-	res := &Articles{}
-	startIDs := []string{notionWebsiteStartPage}
-	res.idToPage = loadAllPages(c, startIDs, useCacheForNotion)
+	loadPage := newNotionPageLoader(c, useCacheForNotion)
+	idToPage, idToParentID := loadAllPages(rootPageIDs, loadPage)
+	var idToRow map[string]*collectionRow
+	if collectionPageID != "" {
+		idToRow = loadCollectionPages(collectionPageID, idToPage, idToParentID, loadPage)
+	}
+	return articlesFromPages(c, idToPage, idToParentID, idToRow)
+}
 
+// articlesFromPages converts notion pages to articles. Pages that are
+// rows in idToRow are blog posts with metadata from row's properties.
+// With -keep-going, pages that fail to convert are skipped
+func articlesFromPages(c *notionapi.Client, idToPage map[string]*notionapi.Page, idToParentID map[string]string, idToRow map[string]*collectionRow) *Articles {
+	res := &Articles{
+		idToPage:     idToPage,
+		idToParentID: idToParentID,
+		idToRow:      idToRow,
+	}
 	res.idToArticle = map[string]*Article{}
 	for id, page := range res.idToPage {
 		panicIf(id != normalizeID(id), "bad id '%s' sneaked in", id)
-		article := notionPageToArticle(c, page)
+		var article *Article
+		ok := processPage(id, func() {
+			row := idToRow[id]
+			if row == nil {
+				article = notionPageToArticle(c, page)
+				return
+			}
+			article = notionPageToArticleWithProps(c, page, row.props)
+			article.inBlog = true
+		})
+		if !ok {
+			continue
+		}
+		article.ParentID = idToParentID[id]
 		if article.urlOverride != "" {
 			verbose("url override: %s => %s\n", article.urlOverride, article.ID)
 		}
@@ -506,22 +1058,162 @@ func loadArticles(c *notionapi.Client) *Articles {
 		res.articles = append(res.articles, article)
 	}
 
-	for _, article := range res.articles {
-		html, images := notionToHTML(c, article.page, res)
-		article.BodyHTML = string(html)
-		article.HTMLBody = template.HTML(article.BodyHTML)
-		article.Images = append(article.Images, images...)
+	// converting to html is cpu-bound and each page is independent
+	isOK := make([]bool, len(res.articles))
+	forEachConcurrently(len(res.articles), flgConcurrency, func(i int) {
+		article := res.articles[i]
+		isOK[i] = processPage(article.page.ID, func() {
+			html, images := notionToHTML(c, article.page, res)
+			article.BodyHTML = string(html)
+			article.HTMLBody = template.HTML(article.BodyHTML)
+			article.Images = append(article.Images, images...)
+			article.ReadingTime = estimateReadingTime(article.page)
+			article.HasMath = hasEquations(article.page)
+			article.HasCode = hasCodeBlocks(article.page)
+		})
+	})
+	var failed []*Article
+	for i, article := range res.articles {
+		if !isOK[i] {
+			failed = append(failed, article)
+		}
+	}
+	for _, article := range failed {
+		res.remove(article)
 	}
 
 	buildArticlesNavigation(res)
+	sortArticlesNewestFirst(res.blog)
+	linkPrevNextArticles(res.getBlogNotHidden())
+	linkRelatedArticles(res.getBlogNotHidden(), relatedArticlesCount)
+
+	return res
+}
+
+// pageIDOf returns id of notion page of the article, which (unlike
+// ID which can be set with "id:" metadata) is always unique
+func pageIDOf(article *Article) string {
+	if article.page == nil {
+		return article.ID
+	}
+	return normalizeID(article.page.ID)
+}
+
+// checkDuplicateIDs returns an error if articles have the same id or url,
+// in which case one would over-write the output of the other
+func checkDuplicateIDs(articles []*Article) error {
+	idToPageIDs := map[string][]string{}
+	urlToPageIDs := map[string][]string{}
+	for _, article := range articles {
+		if !article.shouldGenerate() {
+			continue
+		}
+		pageID := pageIDOf(article)
+		idToPageIDs[article.ID] = append(idToPageIDs[article.ID], pageID)
+		uri := article.URL()
+		urlToPageIDs[uri] = append(urlToPageIDs[uri], pageID)
+	}
+	var errs []string
+	addErrors := func(what string, m map[string][]string) {
+		var keys []string
+		for k, pageIDs := range m {
+			if len(pageIDs) > 1 {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			errs = append(errs, fmt.Sprintf("duplicate %s '%s' in pages %s", what, k, strings.Join(m[k], ", ")))
+		}
+	}
+	addErrors("id", idToPageIDs)
+	addErrors("url", urlToPageIDs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
 
-	sort.Slice(res.blog, func(i, j int) bool {
-		return res.blog[i].PublishedOn.After(res.blog[j].PublishedOn)
+// sortArticlesNewestFirst sorts articles by PublishedOn, most recent first
+func sortArticlesNewestFirst(articles []*Article) {
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].PublishedOn.After(articles[j].PublishedOn)
 	})
+}
 
+// linkPrevNextArticles sets PrevArticle (older) and NextArticle (newer)
+// of articles sorted newest first
+func linkPrevNextArticles(articles []*Article) {
+	n := len(articles)
+	for i, a := range articles {
+		a.PrevArticle = nil
+		a.NextArticle = nil
+		if i > 0 {
+			a.NextArticle = articles[i-1]
+		}
+		if i < n-1 {
+			a.PrevArticle = articles[i+1]
+		}
+	}
+}
+
+// how many related articles are shown at the bottom of an article
+const relatedArticlesCount = 3
+
+// findRelated returns up to n articles from all that share the most tags with
+// article. Articles with the same number of shared tags are ordered newest
+// first. Hidden articles and article itself are not included
+func findRelated(article *Article, all []*Article, n int) []*Article {
+	if len(article.Tags) == 0 {
+		return nil
+	}
+	tags := map[string]bool{}
+	for _, tag := range article.Tags {
+		tags[tag] = true
+	}
+	type scored struct {
+		article *Article
+		score   int
+	}
+	var candidates []scored
+	for _, a := range all {
+		if a == article || a.IsHidden() {
+			continue
+		}
+		score := 0
+		for _, tag := range a.Tags {
+			if tags[tag] {
+				score++
+			}
+		}
+		if score > 0 {
+			candidates = append(candidates, scored{a, score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.score != cj.score {
+			return ci.score > cj.score
+		}
+		return ci.article.PublishedOn.After(cj.article.PublishedOn)
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	var res []*Article
+	for _, c := range candidates {
+		res = append(res, c.article)
+	}
 	return res
 }
 
+// linkRelatedArticles sets RelatedArticles of articles
+func linkRelatedArticles(articles []*Article, n int) {
+	for _, a := range articles {
+		a.RelatedArticles = findRelated(a, articles, n)
+	}
+}
+
 // MonthArticle combines article and a month
 type MonthArticle struct {
 	*Article
@@ -547,7 +1239,12 @@ func NewYear(name string) *Year {
 	return &Year{Name: name, Articles: make([]MonthArticle, 0)}
 }
 
+// buildYearsFromArticles groups articles by year of publishing. Years and
+// articles within a year are ordered newest first
 func buildYearsFromArticles(articles []*Article) []Year {
+	// don't re-order caller's slice
+	articles = append([]*Article(nil), articles...)
+	sortArticlesNewestFirst(articles)
 	res := make([]Year, 0)
 	var currYear *Year
 	var currMonthName string
@@ -596,3 +1293,69 @@ func filterArticlesByTag(articles []*Article, tag string, include bool) []*Artic
 	}
 	return res
 }
+
+// tagSlug returns a version of the tag safe to use in urls
+// e.g. "Web Dev" => "web-dev", "c++" => "cplusplus"
+func tagSlug(tag string) string {
+	// must manually resolve conflict due to urlify
+	switch tag {
+	case "c#":
+		tag = "csharp"
+	case "c++":
+		tag = "cplusplus"
+	}
+	return urlify(tag)
+}
+
+func tagURL(tag string) string {
+	return "/tag/" + tagSlug(tag)
+}
+
+func authorURL(author string) string {
+	return "/author/" + slugify(author)
+}
+
+// groupArticlesByAuthor returns articles for each author, sorted newest first
+func groupArticlesByAuthor(articles []*Article) map[string][]*Article {
+	res := map[string][]*Article{}
+	for _, a := range articles {
+		if a.Author != "" {
+			res[a.Author] = append(res[a.Author], a)
+		}
+	}
+	for _, byAuthor := range res {
+		sortArticlesNewestFirst(byAuthor)
+	}
+	return res
+}
+
+// groupArticlesByCollection returns articles for each collection with
+// generated index page, sorted newest first
+func groupArticlesByCollection(articles []*Article) map[string][]*Article {
+	res := map[string][]*Article{}
+	for _, a := range articles {
+		if a.hasCollectionPage() {
+			res[a.Collection] = append(res[a.Collection], a)
+		}
+	}
+	for _, inCollection := range res {
+		sortArticlesNewestFirst(inCollection)
+	}
+	return res
+}
+
+// groupArticlesByTag returns articles for each tag, sorted newest first
+func groupArticlesByTag(articles []*Article) map[string][]*Article {
+	res := map[string][]*Article{}
+	for _, a := range articles {
+		for _, tag := range a.Tags {
+			res[tag] = append(res[tag], a)
+		}
+	}
+	for _, tagged := range res {
+		sort.SliceStable(tagged, func(i, j int) bool {
+			return tagged[i].PublishedOn.After(tagged[j].PublishedOn)
+		})
+	}
+	return res
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func articleTitles(articles []*Article) []string {
+	var res []string
+	for _, a := range articles {
+		res = append(res, a.Title)
+	}
+	return res
+}
+
+func TestGroupArticlesByTag(t *testing.T) {
+	a1 := mkTestArticle("1", "go old", "2017-01-01", statusNormal)
+	a1.Tags = []string{"go", "programming"}
+	a2 := mkTestArticle("2", "go new", "2019-01-01", statusNormal)
+	a2.Tags = []string{"go"}
+	a3 := mkTestArticle("3", "c++", "2018-01-01", statusNormal)
+	a3.Tags = []string{"c++", "programming"}
+
+	got := groupArticlesByTag([]*Article{a1, a2, a3})
+	assert.Equal(t, 3, len(got))
+	assert.Equal(t, []string{"go new", "go old"}, articleTitles(got["go"]))
+	assert.Equal(t, []string{"c++", "go old"}, articleTitles(got["programming"]))
+	assert.Equal(t, []string{"c++"}, articleTitles(got["c++"]))
+}
+
+func TestAuthorMeta(t *testing.T) {
+	prevAuthor := flgAuthor
+	defer func() {
+		flgAuthor = prevAuthor
+	}()
+	flgAuthor = ""
+
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Author: Jan Kowalski"),
+		mkTestBlock("b2", notionapi.BlockText, "Article text"),
+	)
+	article := notionPageToArticle(nil, page)
+	assert.Equal(t, "Jan Kowalski", article.Author)
+	assert.Equal(t, "/author/jan-kowalski", article.AuthorURL())
+	assert.Equal(t, 1, len(article.page.Root.Content))
+
+	// without author: metadata it's site-wide author
+	page = mkTestPageWithBlocks(mkTestBlock("b1", notionapi.BlockText, "Article text"))
+	article = notionPageToArticle(nil, page)
+	assert.Equal(t, defaultAuthor, article.Author)
+
+	flgAuthor = "Guest Writer"
+	article = notionPageToArticle(nil, page)
+	assert.Equal(t, "Guest Writer", article.Author)
+}
+
+func TestGroupArticlesByAuthor(t *testing.T) {
+	a1 := mkTestArticle("1", "old", "2017-01-01", statusNormal)
+	a1.Author = "Jan"
+	a2 := mkTestArticle("2", "new", "2019-01-01", statusNormal)
+	a2.Author = "Jan"
+	a3 := mkTestArticle("3", "other", "2018-01-01", statusNormal)
+	a3.Author = "Zażółć Gęślą"
+	a4 := mkTestArticle("4", "no author", "2018-01-01", statusNormal)
+
+	got := groupArticlesByAuthor([]*Article{a1, a2, a3, a4})
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, []string{"new", "old"}, articleTitles(got["Jan"]))
+	assert.Equal(t, []string{"other"}, articleTitles(got["Zażółć Gęślą"]))
+	assert.Equal(t, "/author/zazolc-gesla", authorURL("Zażółć Gęślą"))
+}
+
+func TestTagSlug(t *testing.T) {
+	tests := []struct {
+		tag string
+		exp string
+	}{
+		{"go", "go"},
+		{"Web Dev", "web-dev"},
+		{"c++", "cplusplus"},
+		{"c#", "csharp"},
+	}
+	for _, test := range tests {
+		got := tagSlug(test.tag)
+		assert.Equal(t, test.exp, got)
+	}
+}
+
+func recoverPanicMsg(fn func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	fn()
+	return ""
+}
+
+func TestNotionPageToArticleUnknownMeta(t *testing.T) {
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Tgs: go"),
+		mkTestBlock("b2", notionapi.BlockText, "Id: 5"),
+		mkTestBlock("b3", notionapi.BlockText, "Descrption: foo"),
+		mkTestBlock("b4", notionapi.BlockText, "Status: hidden"),
+		mkTestBlock("b5", notionapi.BlockText, "Article text"),
+	)
+	msg := recoverPanicMsg(func() {
+		notionPageToArticle(nil, page)
+	})
+	assert.Contains(t, msg, "has 2 unsupported meta keys")
+	assert.Contains(t, msg, "block 0: unknown key 'tgs' in 'Tgs: go'")
+	assert.Contains(t, msg, "block 2: unknown key 'descrption' in 'Descrption: foo'")
+}
+
+func TestNotionPageToArticleTrailingUnknownMeta(t *testing.T) {
+	// "key: value" text after metadata is part of the content
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Id: 5"),
+		mkTestBlock("b2", notionapi.BlockText, "Note: this is text"),
+		mkTestBlock("b3", notionapi.BlockText, "Article text"),
+	)
+	article := notionPageToArticle(nil, page)
+	assert.Equal(t, 2, len(article.page.Root.Content))
+	assert.Equal(t, "b2", article.page.Root.Content[0].ID)
+}
+
+func TestNotionPageToArticleContinuationMeta(t *testing.T) {
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Description: A long description"),
+		mkTestBlock("b2", notionapi.BlockText, "   that wraps over lines"),
+		mkTestBlock("b3", notionapi.BlockText, "Tags: go,"),
+		mkTestBlock("b4", notionapi.BlockText, "\tprogramming"),
+		mkTestBlock("b5", notionapi.BlockText, "Article text"),
+		mkTestBlock("b6", notionapi.BlockText, "  indented text"),
+	)
+	article := notionPageToArticle(nil, page)
+	assert.Equal(t, "A long description that wraps over lines", article.Description)
+	assert.Equal(t, []string{"go", "programming"}, article.Tags)
+	// un-indented line is article text, not a continuation
+	assert.Equal(t, 2, len(article.page.Root.Content))
+	assert.Equal(t, "b5", article.page.Root.Content[0].ID)
+
+	// only description and tags can continue so indented first paragraph
+	// after other keys is article text
+	page = mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Date: 2019-01-01"),
+		mkTestBlock("b2", notionapi.BlockText, " Indented first paragraph"),
+	)
+	article = notionPageToArticle(nil, page)
+	assert.Equal(t, "2019-01-01", article.PublishedOn.Format("2006-01-02"))
+	assert.Equal(t, 1, len(article.page.Root.Content))
+	assert.Equal(t, "b2", article.page.Root.Content[0].ID)
+
+	// indented line with ':' is not a continuation
+	page = mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Description: text"),
+		mkTestBlock("b2", notionapi.BlockText, "  RedirectFrom: /old.html"),
+		mkTestBlock("b3", notionapi.BlockText, "  more text"),
+	)
+	article = notionPageToArticle(nil, page)
+	assert.Equal(t, "text", article.Description)
+	assert.Equal(t, 1, len(article.page.Root.Content))
+	assert.Equal(t, "b3", article.page.Root.Content[0].ID)
+}
+
+func TestDraftStatus(t *testing.T) {
+	status, err := parseStatus("Draft")
+	assert.NoError(t, err)
+	assert.Equal(t, statusDraft, status)
+
+	draft := mkTestArticle("1", "Draft", "2019-01-02", statusDraft)
+	published := mkTestArticle("2", "Published", "2019-01-01", statusNormal)
+	assert.True(t, draft.IsDraft())
+	assert.True(t, draft.IsHidden())
+	assert.False(t, published.IsDraft())
+
+	prev := flgIncludeDrafts
+	defer func() {
+		flgIncludeDrafts = prev
+	}()
+	flgIncludeDrafts = false
+	assert.False(t, draft.shouldGenerate())
+	assert.True(t, published.shouldGenerate())
+	flgIncludeDrafts = true
+	assert.True(t, draft.shouldGenerate())
+	assert.True(t, published.shouldGenerate())
+
+	// drafts are never listed, even with -include-drafts
+	store := mkTestArticles(draft, published)
+	assert.Equal(t, []string{"Published"}, articleTitles(store.getNotHidden()))
+	assert.Equal(t, []string{"Published"}, articleTitles(store.getBlogNotHidden()))
+	d, err := genRSSFeed(store)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(d), "Draft")
+	d, err = genSiteMap(store, "")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(d), "/article/draft-1/")
+}
+
+func TestRequiredMeta(t *testing.T) {
+	assert.Equal(t, []string{"date", "tags"}, parseRequiredMetaKeys(" Date, tags,,"))
+	assert.Equal(t, []string{"date"}, parseRequiredMetaKeys("publishedon"))
+
+	prevKeys := requiredMetaKeys
+	prevStrict := flgStrictMeta
+	defer func() {
+		requiredMetaKeys = prevKeys
+		flgStrictMeta = prevStrict
+	}()
+	requiredMetaKeys = []string{"date"}
+	created := time.Date(2018, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	mkPage := func(meta ...string) *notionapi.Page {
+		var blocks []*notionapi.Block
+		for i, s := range meta {
+			blocks = append(blocks, mkTestBlock(fmt.Sprintf("m%d", i), notionapi.BlockText, s))
+		}
+		blocks = append(blocks, mkTestBlock("b1", notionapi.BlockHeader, "Header"))
+		page := mkTestPageWithBlocks(blocks...)
+		page.Root.CreatedTime = created.Unix() * 1000
+		return page
+	}
+
+	flgStrictMeta = true
+	article := notionPageToArticle(nil, mkPage("Date: 2019-01-02"))
+	assert.Equal(t, "2019-01-02", article.PublishedOn.Format("2006-01-02"))
+	article = notionPageToArticle(nil, mkPage("PublishedOn: 2019-01-03"))
+	assert.Equal(t, "2019-01-03", article.PublishedOn.Format("2006-01-02"))
+
+	msg := recoverPanicMsg(func() {
+		notionPageToArticle(nil, mkPage("Tags: go"))
+	})
+	assert.Contains(t, msg, "is missing required meta keys: date")
+
+	// page without any metadata
+	msg = recoverPanicMsg(func() {
+		notionPageToArticle(nil, mkPage())
+	})
+	assert.Contains(t, msg, "is missing required meta keys: date")
+
+	// not strict: we warn and use page's creation time
+	flgStrictMeta = false
+	article = notionPageToArticle(nil, mkPage())
+	assert.True(t, article.PublishedOn.Equal(created))
+}
+
+func TestSortArticlesNewestFirst(t *testing.T) {
+	articles := []*Article{
+		mkTestArticle("1", "Middle", "2018-06-01", statusNormal),
+		mkTestArticle("2", "Oldest", "2017-01-01", statusNormal),
+		mkTestArticle("3", "Newest", "2019-03-01", statusNormal),
+	}
+	sortArticlesNewestFirst(articles)
+	assert.Equal(t, []string{"Newest", "Middle", "Oldest"}, articleTitles(articles))
+
+	store := mkTestArticles(articles...)
+	d, err := genRSSFeed(store)
+	assert.NoError(t, err)
+	s := string(d)
+	iNewest := strings.Index(s, "Newest")
+	iMiddle := strings.Index(s, "Middle")
+	iOldest := strings.Index(s, "Oldest")
+	assert.True(t, iNewest < iMiddle && iMiddle < iOldest)
+}
+
+func TestArticleSlug(t *testing.T) {
+	a := mkTestArticle("2c3d4e5f6a7b4c8d9e0f1a2b3c4d5e6f", "MicroConf videos", "2019-01-01", statusNormal)
+	assert.Equal(t, "microconf-videos-2c3d4e5f", a.Slug())
+	assert.Equal(t, "/article/microconf-videos-2c3d4e5f/", a.URL())
+
+	a.Title = "!!!"
+	assert.Equal(t, "2c3d4e5f", a.Slug())
+
+	a = mkTestArticle("1Y", "", "2019-01-01", statusNormal)
+	assert.Equal(t, "1Y", a.Slug())
+
+	a.urlOverride = "/software/"
+	assert.Equal(t, "/software/", a.URL())
+}
+
+func TestBuildYearsFromArticles(t *testing.T) {
+	articles := []*Article{
+		mkTestArticle("1", "a 2017", "2017-05-01", statusNormal),
+		mkTestArticle("2", "b 2019", "2019-01-10", statusNormal),
+		mkTestArticle("3", "c 2018", "2018-03-01", statusNormal),
+		mkTestArticle("4", "d 2019", "2019-02-01", statusNormal),
+		mkTestArticle("5", "e 2017", "2017-12-24", statusNormal),
+		mkTestArticle("6", "f 2019", "2019-02-15", statusNormal),
+	}
+	years := buildYearsFromArticles(articles)
+	assert.Equal(t, 3, len(years))
+	var names []string
+	for _, y := range years {
+		names = append(names, y.Name)
+	}
+	assert.Equal(t, []string{"2019", "2018", "2017"}, names)
+
+	yearTitles := func(y Year) []string {
+		var res []string
+		for _, ma := range y.Articles {
+			res = append(res, ma.Title)
+		}
+		return res
+	}
+	assert.Equal(t, []string{"f 2019", "d 2019", "b 2019"}, yearTitles(years[0]))
+	assert.Equal(t, []string{"c 2018"}, yearTitles(years[1]))
+	assert.Equal(t, []string{"e 2017", "a 2017"}, yearTitles(years[2]))
+	// month is only shown for the first article in a given month
+	assert.Equal(t, "February 15", years[0].Articles[0].DisplayMonth)
+	assert.Equal(t, "1", years[0].Articles[1].DisplayMonth)
+	// caller's slice is not re-ordered
+	assert.Equal(t, "a 2017", articles[0].Title)
+}
+
+func TestLinkPrevNextArticles(t *testing.T) {
+	newest := mkTestArticle("3", "Newest", "2019-03-01", statusNormal)
+	middle := mkTestArticle("2", "Middle", "2019-02-01", statusNormal)
+	oldest := mkTestArticle("1", "Oldest", "2019-01-01", statusNormal)
+	linkPrevNextArticles([]*Article{newest, middle, oldest})
+
+	assert.Equal(t, oldest, middle.PrevArticle)
+	assert.Equal(t, newest, middle.NextArticle)
+	assert.Equal(t, middle, newest.PrevArticle)
+	assert.Nil(t, newest.NextArticle)
+	assert.Nil(t, oldest.PrevArticle)
+	assert.Equal(t, middle, oldest.NextArticle)
+
+	s := execTestArticleTemplate(t, middle)
+	assert.Contains(t, s, `<a href="/article/oldest-1/">&larr; Oldest</a>`)
+	assert.Contains(t, s, `<a href="/article/newest-3/">Newest &rarr;</a>`)
+	s = execTestArticleTemplate(t, newest)
+	assert.Contains(t, s, "&larr; Middle")
+	assert.NotContains(t, s, "&rarr;")
+	s = execTestArticleTemplate(t, oldest)
+	assert.NotContains(t, s, "&larr;")
+	assert.Contains(t, s, "Middle &rarr;")
+}
+
+func TestPageCoverAndIcon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png data " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	page := mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "Article text"))
+	coverURL := srv.URL + "/secure.notion-static.com/1234/cover.png"
+	page.Root.FormatPage = &notionapi.FormatPage{
+		PageCover: coverURL,
+		PageIcon:  "🚀",
+	}
+	article := notionPageToArticle(&notionapi.Client{}, page)
+	coverName := sha1OfLink(coverURL) + ".png"
+	assert.Equal(t, "https://blog.kowalczyk.info/img/"+coverName, article.HeaderImageURL)
+	assert.Equal(t, "🚀", article.IconEmoji)
+	assert.Empty(t, article.IconURL)
+
+	s := execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<img class="hdr-image hide-mobile" src="https://blog.kowalczyk.info/img/`+coverName+`">`)
+	assert.Contains(t, s, `<span class="page-icon">🚀</span>`)
+
+	// image icon is downloaded
+	iconURL := srv.URL + "/secure.notion-static.com/1234/icon.png"
+	page.Root.FormatPage.PageIcon = iconURL
+	article = notionPageToArticle(&notionapi.Client{}, page)
+	iconName := sha1OfLink(iconURL) + ".png"
+	assert.Equal(t, "/img/"+iconName, article.IconURL)
+	assert.Equal(t, 2, len(article.Images))
+	s = execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<img class="page-icon" src="/img/`+iconName+`" alt="">`)
+
+	assert.Equal(t, "https://www.notion.so/images/page-cover/gradients_11.jpg", notionCoverURL("/images/page-cover/gradients_11.jpg"))
+}
+
+func TestHeaderImage(t *testing.T) {
+	nRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		w.Header().Set("Content-Type", "image/png")
+		// different content so that images are not de-duplicated
+		w.Write([]byte("png data " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	headerImage := func(val string, blocks ...*notionapi.Block) *Article {
+		meta := mkTestBlock("m1", notionapi.BlockText, "HeaderImage: "+val)
+		page := mkTestPageWithBlocks(append([]*notionapi.Block{meta}, blocks...)...)
+		return notionPageToArticle(&notionapi.Client{}, page)
+	}
+
+	// a literal url is used as is
+	article := headerImage("https://example.com/header.png")
+	assert.Equal(t, "https://example.com/header.png", article.HeaderImageURL)
+	assert.Empty(t, article.Images)
+	assert.Equal(t, 0, nRequests)
+
+	// image block in the page is downloaded
+	imgBlockID := "2c3d4e5f-0000-4000-8000-000000000001"
+	imgBlock := mkTestBlock(imgBlockID, notionapi.BlockImage, "")
+	imgBlock.Source = srv.URL + "/block-image"
+	for _, val := range []string{imgBlockID, "https://www.notion.so/Title-" + mkTestPageID(1) + "#" + normalizeID(imgBlockID)} {
+		article = headerImage(val, imgBlock)
+		name := sha1OfLink(imgBlock.Source) + ".png"
+		assert.Equal(t, "https://blog.kowalczyk.info/img/"+name, article.HeaderImageURL)
+		assert.Equal(t, 1, len(article.Images))
+		assert.Equal(t, "/img/"+name, article.Images[0].relativeURL)
+	}
+
+	// file uploaded to notion is downloaded
+	fileURL := srv.URL + "/secure.notion-static.com/1234/header.png"
+	article = headerImage(fileURL)
+	name := sha1OfLink(fileURL) + ".png"
+	assert.Equal(t, "https://blog.kowalczyk.info/img/"+name, article.HeaderImageURL)
+	_, err = os.Stat(filepath.Join(dir, "img", name))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, nRequests)
+}
+
+func TestKeepGoing(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	prevKeepGoing := flgKeepGoing
+	flgKeepGoing = true
+	pageFailures = nil
+	defer func() {
+		flgKeepGoing = prevKeepGoing
+		pageFailures = nil
+	}()
+
+	mkPage := func(n int, blocks ...*notionapi.Block) *notionapi.Page {
+		page := mkTestPageWithBlocks(blocks...)
+		page.ID = mkTestPageID(n)
+		page.Root.ID = page.ID
+		page.Root.Title = fmt.Sprintf("Page %d", n)
+		return page
+	}
+	idToPage := map[string]*notionapi.Page{
+		mkTestPageID(1): mkPage(1, mkTestBlock("t1", notionapi.BlockText, "first")),
+		// invalid status panics in notionPageToArticle()
+		mkTestPageID(2): mkPage(2, mkTestBlock("m2", notionapi.BlockText, "Status: bogus")),
+		mkTestPageID(3): mkPage(3, mkTestBlock("t3", notionapi.BlockText, "third")),
+	}
+	store := articlesFromPages(nil, idToPage, nil, nil)
+	titles := articleTitles(store.articles)
+	sort.Strings(titles)
+	assert.Equal(t, []string{"Page 1", "Page 3"}, titles)
+	assert.Nil(t, store.idToArticle[mkTestPageID(2)])
+	for _, article := range store.articles {
+		path := netlifyArticlePath(article)
+		assert.True(t, netlifyWriteArticle(article, path, false))
+		assert.FileExists(t, netlifyPath(path))
+	}
+
+	assert.Equal(t, 1, len(pageFailures))
+	assert.Equal(t, mkTestPageID(2), pageFailures[0].pageID)
+	assert.Equal(t, 1, reportPageFailures())
+
+	// without -keep-going the panic is not recovered
+	flgKeepGoing = false
+	msg := recoverPanicMsg(func() {
+		articlesFromPages(nil, idToPage, nil, nil)
+	})
+	assert.NotEmpty(t, msg)
+}
+
+func TestFindRelated(t *testing.T) {
+	mk := func(id string, date string, status int, tags ...string) *Article {
+		a := mkTestArticle(id, "Article "+id, date, status)
+		a.Tags = tags
+		return a
+	}
+	article := mk("1", "2019-01-01", statusNormal, "go", "notion", "web")
+	all := []*Article{
+		article,
+		mk("2", "2019-02-01", statusNormal, "go"),
+		mk("3", "2019-03-01", statusNormal, "go", "notion"),
+		mk("4", "2018-01-01", statusNormal, "go", "notion", "web"),
+		mk("5", "2019-04-01", statusNormal, "web"),
+		mk("6", "2019-05-01", statusNormal, "python"),
+		mk("7", "2019-06-01", statusHidden, "go", "notion", "web"),
+		mk("8", "2019-07-01", statusNotImportant, "go", "notion"),
+	}
+	related := findRelated(article, all, 3)
+	// most shared tags first, newer first if the same number of shared tags
+	assert.Equal(t, []string{"Article 4", "Article 3", "Article 5"}, articleTitles(related))
+	related = findRelated(article, all, 10)
+	assert.Equal(t, []string{"Article 4", "Article 3", "Article 5", "Article 2"}, articleTitles(related))
+	assert.Nil(t, findRelated(mk("9", "2019-01-01", statusNormal), all, 3))
+
+	linkRelatedArticles(all[:4], 2)
+	assert.Equal(t, []string{"Article 1", "Article 3"}, articleTitles(all[3].RelatedArticles))
+	s := execTestArticleTemplate(t, all[3])
+	assert.Contains(t, s, `<li><a href="/article/article-1-1/">Article 1</a></li>`)
+	assert.Contains(t, s, `<li><a href="/article/article-3-3/">Article 3</a></li>`)
+}
+
+func TestCheckDuplicateIDs(t *testing.T) {
+	mk := func(pageID int, id string, title string) *Article {
+		a := mkTestArticle(id, title, "2019-01-01", statusNormal)
+		a.hasCustomID = true
+		a.page = mkTestPage(mkTestPageID(pageID))
+		return a
+	}
+	first := mk(1, "go-tips", "Go tips")
+	second := mk(2, "go-tips", "More Go tips")
+	other := mk(3, "other", "Other")
+	assert.NoError(t, checkDuplicateIDs([]*Article{first, other}))
+
+	err := checkDuplicateIDs([]*Article{first, other, second})
+	assert.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, "duplicate id 'go-tips' in pages "+mkTestPageID(1)+", "+mkTestPageID(2))
+	assert.Contains(t, msg, "duplicate url '/article/go-tips/'")
+	assert.NotContains(t, msg, mkTestPageID(3))
+
+	// the same url set with url: metadata
+	other.urlOverride = "/article/go-tips/"
+	second.ID = "more-go-tips"
+	err = checkDuplicateIDs([]*Article{first, other, second})
+	assert.Error(t, err)
+	assert.Equal(t, "duplicate url '/article/go-tips/' in pages "+mkTestPageID(1)+", "+mkTestPageID(3), err.Error())
+
+	// drafts are not generated so they don't conflict
+	other.Status = statusDraft
+	assert.NoError(t, checkDuplicateIDs([]*Article{first, other, second}))
+}
+
+func TestScheduledArticles(t *testing.T) {
+	prevIncludeFuture := flgIncludeFuture
+	defer func() {
+		flgIncludeFuture = prevIncludeFuture
+	}()
+	future := time.Now().Add(48 * time.Hour).Format("2006-01-02")
+	scheduled := mkTestArticle("1", "Scheduled", future, statusNormal)
+	published := mkTestArticle("2", "Published", "2019-01-01", statusNormal)
+
+	flgIncludeFuture = false
+	assert.True(t, scheduled.IsScheduled())
+	assert.False(t, published.IsScheduled())
+	store := mkTestArticles(scheduled, published)
+	assert.Equal(t, []string{"Published"}, articleTitles(store.getBlogNotHidden()))
+	d, err := genRSSFeed(store)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(d), "Scheduled")
+	d, err = genAtomXML(store, false)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(d), "Scheduled")
+	d, err = genSiteMap(store, "")
+	assert.NoError(t, err)
+	assert.NotContains(t, sitemapArticleURLs(t, d, ""), scheduled.URL())
+	// it's still generated, just not listed
+	assert.True(t, scheduled.shouldGenerate())
+
+	flgIncludeFuture = true
+	assert.False(t, scheduled.IsScheduled())
+	store = mkTestArticles(scheduled, published)
+	assert.Equal(t, []string{"Scheduled", "Published"}, articleTitles(store.getBlogNotHidden()))
+	d, err = genRSSFeed(store)
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), "Scheduled")
+	d, err = genSiteMap(store, "")
+	assert.NoError(t, err)
+	assert.Contains(t, sitemapArticleURLs(t, d, ""), scheduled.URL())
+}
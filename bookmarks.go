@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kjk/notionapi"
+)
+
+// bookmarkInfo is what we show in a link card for a bookmark block
+type bookmarkInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+}
+
+// format of bookmark block. notionapi.FormatBookmark doesn't have cover
+type formatBookmark struct {
+	BookmarkIcon  string `json:"bookmark_icon"`
+	BookmarkCover string `json:"bookmark_cover"`
+}
+
+// bookmarkInfoFromBlock returns information notion saved in the block
+func bookmarkInfoFromBlock(block *notionapi.Block) *bookmarkInfo {
+	res := &bookmarkInfo{
+		Title:       strings.TrimSpace(inlinesToText(block.InlineContent)),
+		Description: strings.TrimSpace(block.Description),
+	}
+	var f formatBookmark
+	if len(block.FormatRaw) > 0 && json.Unmarshal(block.FormatRaw, &f) == nil {
+		res.ImageURL = f.BookmarkCover
+	}
+	return res
+}
+
+var (
+	metaTagRx  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrRx = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+	titleTagRx = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// parseOpenGraph extracts og:title, og:description and og:image from html
+func parseOpenGraph(d []byte) *bookmarkInfo {
+	res := &bookmarkInfo{}
+	for _, tag := range metaTagRx.FindAll(d, -1) {
+		var prop, content string
+		for _, m := range metaAttrRx.FindAllSubmatch(tag, -1) {
+			v := html.UnescapeString(strings.TrimSpace(string(m[2][1 : len(m[2])-1])))
+			if strings.EqualFold(string(m[1]), "content") {
+				content = v
+			} else {
+				prop = strings.ToLower(v)
+			}
+		}
+		switch prop {
+		case "og:title":
+			res.Title = content
+		case "og:description":
+			res.Description = content
+		case "og:image":
+			res.ImageURL = content
+		}
+	}
+	if res.Title == "" {
+		if m := titleTagRx.FindSubmatch(d); m != nil {
+			res.Title = strings.TrimSpace(html.UnescapeString(string(m[1])))
+		}
+	}
+	return res
+}
+
+func fetchBookmarkInfo(uri string) (*bookmarkInfo, error) {
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+	}
+	rsp, err := client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("'%s' returned status code %d", uri, rsp.StatusCode)
+	}
+	// meta tags are in <head> so no need to read huge pages fully
+	d, err := ioutil.ReadAll(io.LimitReader(rsp.Body, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	res := parseOpenGraph(d)
+	if res.Title == "" {
+		return nil, fmt.Errorf("'%s' has no title", uri)
+	}
+	// image url might be relative to the page
+	if res.ImageURL != "" {
+		if u, err := rsp.Request.URL.Parse(res.ImageURL); err == nil {
+			res.ImageURL = u.String()
+		}
+	}
+	return res, nil
+}
+
+// fetchAndCacheBookmarkInfo fetches open graph data for uri, unless
+// it's already cached in cacheDir
+func fetchAndCacheBookmarkInfo(uri string) (*bookmarkInfo, error) {
+	dir := filepath.Join(cacheDir, "bookmarks")
+	cachedPath := filepath.Join(dir, sha1OfLink(uri)+".json")
+	if d, err := ioutil.ReadFile(cachedPath); err == nil {
+		var res bookmarkInfo
+		if err = json.Unmarshal(d, &res); err == nil {
+			return &res, nil
+		}
+	}
+	lg("Fetching bookmark %s\n", uri)
+	res, err := fetchBookmarkInfo(uri)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	panicIfErr(err)
+	d, err := json.MarshalIndent(res, "", "  ")
+	panicIfErr(err)
+	err = ioutil.WriteFile(cachedPath, d, 0644)
+	panicIfErr(err)
+	return res, nil
+}
+
+// renderBookmark renders bookmark block as a link card. Uses information
+// saved by notion or, with -fetch-bookmarks, open graph data of the page.
+// If we don't have a title, it's just a link
+func (r *HTMLRenderer) renderBookmark(block *notionapi.Block) string {
+	link := block.Link
+	info := bookmarkInfoFromBlock(block)
+	if info.Title == "" && flgFetchBookmarks {
+		fetched, err := fetchAndCacheBookmarkInfo(link)
+		if err != nil {
+			logWarn("Warning: fetching bookmark '%s' from page https://notion.so/%s failed with '%s'\n", link, normalizeID(r.page.ID), err)
+		} else {
+			info = fetched
+		}
+	}
+	uri := html.EscapeString(link)
+	if info.Title == "" {
+		return fmt.Sprintf(`<div class="notion-bookmark"><a href="%s">%s</a></div>`, uri, uri)
+	}
+	s := fmt.Sprintf(`<a class="notion-bookmark-card" href="%s"><div class="notion-bookmark-text">`, uri)
+	s += fmt.Sprintf(`<div class="notion-bookmark-title">%s</div>`, html.EscapeString(info.Title))
+	if info.Description != "" {
+		s += fmt.Sprintf(`<div class="notion-bookmark-description">%s</div>`, html.EscapeString(info.Description))
+	}
+	s += fmt.Sprintf(`<div class="notion-bookmark-link">%s</div></div>`, uri)
+	if info.ImageURL != "" {
+		s += fmt.Sprintf(`<img class="notion-bookmark-image" src="%s">`, html.EscapeString(info.ImageURL))
+	}
+	s += `</a>`
+	return s
+}
+
+// RenderBookmark renders BlockBookmark
+func (r *HTMLRenderer) RenderBookmark(block *notionapi.Block, entering bool) bool {
+	if entering {
+		r.r.WriteIndent()
+		r.r.WriteString(r.renderBookmark(block))
+		r.r.Newline()
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderBookmark(t *testing.T) {
+	block := mkTestBlock("b1", notionapi.BlockBookmark, "Go blog")
+	block.Link = "https://blog.golang.org/"
+	block.Description = "The Go Programming Language Blog"
+	block.FormatRaw = []byte(`{"bookmark_icon":"https://blog.golang.org/favicon.ico","bookmark_cover":"https://blog.golang.org/gopher.png"}`)
+	page := mkTestPageWithBlocks(block)
+
+	s := renderTestPage(page)
+	assert.Contains(t, s, `<a class="notion-bookmark-card" href="https://blog.golang.org/">`)
+	assert.Contains(t, s, `<div class="notion-bookmark-title">Go blog</div>`)
+	assert.Contains(t, s, `<div class="notion-bookmark-description">The Go Programming Language Blog</div>`)
+	assert.Contains(t, s, `<img class="notion-bookmark-image" src="https://blog.golang.org/gopher.png">`)
+
+	// without title it's a plain link
+	block.InlineContent = nil
+	s = renderTestPage(page)
+	assert.Contains(t, s, `<div class="notion-bookmark"><a href="https://blog.golang.org/">https://blog.golang.org/</a></div>`)
+	assert.NotContains(t, s, "notion-bookmark-card")
+}
+
+func TestRenderBookmarkFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_cache")
+	assert.NoError(t, err)
+	prevCacheDir, prevFetch := cacheDir, flgFetchBookmarks
+	cacheDir, flgFetchBookmarks = dir, true
+	defer func() {
+		cacheDir, flgFetchBookmarks = prevCacheDir, prevFetch
+		os.RemoveAll(dir)
+	}()
+
+	nRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		if r.URL.Path != "/post" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>Ignored</title>
+<meta property="og:title" content="Fast &amp; simple">
+<meta content="All about it" property="og:description">
+<meta property="og:image" content="/cover.png">
+</head></html>`)
+	}))
+	defer srv.Close()
+
+	block := mkTestBlock("b1", notionapi.BlockBookmark, "")
+	block.Link = srv.URL + "/post"
+	page := mkTestPageWithBlocks(block)
+	s := renderTestPage(page)
+	assert.Contains(t, s, `<div class="notion-bookmark-title">Fast &amp; simple</div>`)
+	assert.Contains(t, s, `<div class="notion-bookmark-description">All about it</div>`)
+	assert.Contains(t, s, `src="`+srv.URL+`/cover.png"`)
+
+	// fetched data is cached
+	s = renderTestPage(page)
+	assert.Contains(t, s, "notion-bookmark-card")
+	assert.Equal(t, 1, nRequests)
+
+	// failed fetch falls back to a plain link
+	block.Link = srv.URL + "/missing"
+	s = renderTestPage(page)
+	assert.Contains(t, s, `<div class="notion-bookmark"><a href="`+block.Link+`">`)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// version of the tool, can be set at build time with:
+// go build -ldflags "-X main.version=1.2"
+var version = "dev"
+
+// BuildInfo describes a build, written to /build-info.json
+// to help debugging deploys
+type BuildInfo struct {
+	BuildTime      time.Time `json:"build_time"`
+	Version        string    `json:"version"`
+	PagesGenerated int       `json:"pages_generated"`
+	// not re-generated in incremental build because they didn't change
+	PagesSkipped  int `json:"pages_skipped"`
+	DraftsSkipped int `json:"drafts_skipped"`
+}
+
+func netlifyWriteBuildInfo(info *BuildInfo) {
+	info.BuildTime = time.Now().UTC()
+	info.Version = version
+	d, err := json.MarshalIndent(info, "", "  ")
+	panicIfErr(err)
+	netlifyWriteFile("/"+buildInfoFileName, d)
+}
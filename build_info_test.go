@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfo(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	prevRedirects := netlifyRedirects
+	defer func() {
+		netlifyRedirects = prevRedirects
+	}()
+
+	store := mkTestArticles(
+		mkTestArticle("1", "First", "2019-01-01", statusNormal),
+		mkTestArticle("2", "Second", "2019-01-02", statusHidden),
+		mkTestArticle("3", "Draft", "2019-01-03", statusDraft),
+	)
+	info := netlifyWriteArticles(store, false)
+	timeStart := time.Now().UTC()
+	netlifyWriteBuildInfo(info)
+
+	d, err := ioutil.ReadFile(netlifyPath("/build-info.json"))
+	assert.NoError(t, err)
+	var got BuildInfo
+	err = json.Unmarshal(d, &got)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got.PagesGenerated)
+	assert.Equal(t, 0, got.PagesSkipped)
+	assert.Equal(t, 1, got.DraftsSkipped)
+	assert.Equal(t, version, got.Version)
+	assert.False(t, got.BuildTime.Before(timeStart.Truncate(time.Second)))
+	assert.FileExists(t, netlifyPath(netlifyArticlePath(store.articles[0])))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// matches links to articles and tags e.g. href="/article/foo-1234/#intro"
+var internalLinkRx = regexp.MustCompile(`href="(/(?:article|tag)/[^"#?]*)`)
+
+// readRedirectsFile returns redirects from _redirects file in dir
+func readRedirectsFile(dir string) []*netlifyRedirect {
+	d, err := ioutil.ReadFile(filepath.Join(dir, "_redirects"))
+	if err != nil {
+		return nil
+	}
+	var res []*netlifyRedirect
+	for _, l := range strings.Split(string(d), "\n") {
+		parts := strings.Fields(l)
+		if len(parts) < 2 {
+			continue
+		}
+		r := &netlifyRedirect{
+			from: parts[0],
+			to:   parts[1],
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// isLinkTargetValid returns true if uri is served by a file in dir
+// or by one of the redirects
+func isLinkTargetValid(dir string, uri string, redirects []*netlifyRedirect) bool {
+	// limit protects against redirect loops
+	for i := 0; i < 8; i++ {
+		path := filepath.Join(dir, filepath.FromSlash(uri))
+		if strings.HasSuffix(uri, "/") {
+			path = filepath.Join(path, "index.html")
+		}
+		if st, err := os.Stat(path); err == nil {
+			if !st.IsDir() {
+				return true
+			}
+			_, err = os.Stat(filepath.Join(path, "index.html"))
+			return err == nil
+		}
+		var to string
+		for _, r := range redirects {
+			if r.from == uri {
+				to = r.to
+				break
+			}
+			if strings.HasSuffix(r.from, "*") && strings.HasPrefix(uri, strings.TrimSuffix(r.from, "*")) {
+				to = r.to
+				break
+			}
+		}
+		if to == "" {
+			return false
+		}
+		// redirects to other websites are not checked
+		if !strings.HasPrefix(to, "/") {
+			return true
+		}
+		uri = to
+	}
+	return false
+}
+
+// checkInternalLinks scans html files in dir for links to articles and tags
+// and returns descriptions of links that don't point to an existing file
+func checkInternalLinks(dir string) []string {
+	redirects := readRedirectsFile(dir)
+	var res []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".html" {
+			return nil
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			res = append(res, fmt.Sprintf("%s: %s", path, err))
+			return nil
+		}
+		if !bytes.Contains(d, []byte(`href="/`)) {
+			return nil
+		}
+		relPath, _ := filepath.Rel(dir, path)
+		seen := map[string]bool{}
+		for _, m := range internalLinkRx.FindAllSubmatch(d, -1) {
+			uri := html.UnescapeString(string(m[1]))
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			if unescaped, err := url.PathUnescape(uri); err == nil {
+				uri = unescaped
+			}
+			if !isLinkTargetValid(dir, uri, redirects) {
+				res = append(res, fmt.Sprintf("%s: broken link '%s'", filepath.ToSlash(relPath), string(m[1])))
+			}
+		}
+		return nil
+	})
+	sort.Strings(res)
+	return res
+}
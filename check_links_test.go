@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckInternalLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_links")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(path string, s string) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		err := mkdirForFile(path)
+		assert.NoError(t, err)
+		err = ioutil.WriteFile(path, []byte(s), 0644)
+		assert.NoError(t, err)
+	}
+	write("article/valid-1/index.html", "<p>valid</p>")
+	write("tag/go/index.html", "<p>go</p>")
+	write("_redirects", "/article/1.html\t/article/valid-1/\t301\n/article/1/*\t/article/valid-1/\t301\n")
+	write("index.html", `<a href="/article/valid-1/">valid</a>
+<a href="/article/valid-1/#intro">valid with anchor</a>
+<a href="/tag/go">tag</a>
+<a href="/article/1.html">redirected</a>
+<a href="/article/1/old-title.html">redirected</a>
+<a href="/article/missing-2/">dangling</a>
+<a href="/about.html">not checked</a>`)
+
+	broken := checkInternalLinks(dir)
+	assert.Equal(t, []string{"index.html: broken link '/article/missing-2/'"}, broken)
+}
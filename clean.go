@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// file written by every build. We only remove files from directories
+// that have it, to not wipe a directory given as -out by mistake
+const buildInfoFileName = "build-info.json"
+
+// isSameOrParentDir returns true if dir is path or one of its parents
+func isSameOrParentDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || !strings.HasPrefix(rel, "..")
+}
+
+// cleanOutDir removes everything in dir, for -clean. It refuses to do it
+// if dir doesn't look like a generated website
+func cleanOutDir(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if filepath.Dir(absDir) == absDir {
+		return fmt.Errorf("refusing to clean '%s' because it's a root directory", dir)
+	}
+	for _, path := range []string{".", "www"} {
+		absPath, err := filepath.Abs(path)
+		if err == nil && isSameOrParentDir(absDir, absPath) {
+			return fmt.Errorf("refusing to clean '%s' because it contains '%s'", dir, absPath)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && isSameOrParentDir(absDir, home) {
+		return fmt.Errorf("refusing to clean '%s' because it contains home directory", dir)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, buildInfoFileName)); err != nil {
+		return fmt.Errorf("refusing to clean '%s' because it doesn't have %s so it's not a generated website", dir, buildInfoFileName)
+	}
+	for _, fi := range files {
+		err = os.RemoveAll(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanOutDir(t *testing.T) {
+	defer setTestOutDir(t)()
+
+	// previous build
+	netlifyWriteFile("/article/deleted-1/index.html", []byte("deleted"))
+	netlifyWriteFile("/ping", []byte("pong"))
+	netlifyWriteBuildInfo(&BuildInfo{})
+
+	err := cleanOutDir(flgOutDir)
+	assert.NoError(t, err)
+	files, err := ioutil.ReadDir(flgOutDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	// regenerate
+	netlifyWriteFile("/ping", []byte("pong"))
+	netlifyWriteBuildInfo(&BuildInfo{})
+	assert.FileExists(t, filepath.Join(flgOutDir, "ping"))
+	assert.FileExists(t, filepath.Join(flgOutDir, buildInfoFileName))
+	_, err = os.Stat(filepath.Join(flgOutDir, "article"))
+	assert.True(t, os.IsNotExist(err))
+
+	// missing and empty directories are fine
+	assert.NoError(t, cleanOutDir(filepath.Join(flgOutDir, "missing")))
+	assert.NoError(t, os.Mkdir(filepath.Join(flgOutDir, "empty"), 0755))
+	assert.NoError(t, cleanOutDir(filepath.Join(flgOutDir, "empty")))
+}
+
+func TestCleanOutDirRefuses(t *testing.T) {
+	defer setTestOutDir(t)()
+
+	// not generated by us
+	netlifyWriteFile("/notes.txt", []byte("important"))
+	err := cleanOutDir(flgOutDir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not a generated website")
+	assert.FileExists(t, filepath.Join(flgOutDir, "notes.txt"))
+
+	for _, dir := range []string{"/", ".", "..", "www"} {
+		err = cleanOutDir(dir)
+		assert.Error(t, err, "dir: %s", dir)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Config has default values of command-line flags, keyed by flag name e.g.
+// {"out": "www_generated", "base-url": "https://blog.kowalczyk.info", "concurrency": 8}
+// Flags that can be given multiple times take a list of values
+type Config map[string]interface{}
+
+// file with Config. If it doesn't exist, flags have built-in defaults
+var configPath = "blog.json"
+
+// loadConfig reads Config from path. If path doesn't exist and mustExist
+// is false, returns empty Config
+func loadConfig(path string, mustExist bool) (Config, error) {
+	d, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !mustExist {
+		return Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res Config
+	// UseNumber so that large ints are not formatted as floats
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	err = dec.Decode(&res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return res, nil
+}
+
+// applyConfig sets flags in fs to values from config. Flags given
+// on command line over-write values from config so they are not changed
+func applyConfig(fs *flag.FlagSet, config Config) error {
+	setOnCmdLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setOnCmdLine[f.Name] = true
+	})
+
+	var names []string
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("'%s' in config is not a known flag", name)
+		}
+		if setOnCmdLine[name] {
+			continue
+		}
+		values, ok := config[name].([]interface{})
+		if !ok {
+			values = []interface{}{config[name]}
+		}
+		for _, v := range values {
+			if err := fs.Set(name, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("invalid value '%v' of '%s' in config: %s", v, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkOutDir returns an error if generating into dir would over-write
+// source files in www e.g. when config has {"out": "www"} or {"out": "."}
+func checkOutDir(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absWww, err := filepath.Abs("www")
+	if err != nil {
+		return err
+	}
+	if isSameOrParentDir(absDir, absWww) {
+		return fmt.Errorf("-out '%s' can't be www directory or its parent", dir)
+	}
+	return nil
+}
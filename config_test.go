@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blog.json")
+	s := `{
+  "out": "www_generated",
+  "base-url": "https://blog.example.com",
+  "concurrency": 8,
+  "minify": true,
+  "robots-disallow": ["/drafts/", "/tmp/"]
+}`
+	err = ioutil.WriteFile(path, []byte(s), 0644)
+	assert.NoError(t, err)
+
+	var outDir, baseURL string
+	var concurrency int
+	var minify bool
+	var disallow stringsFlag
+	fs := flag.NewFlagSet("blog", flag.ContinueOnError)
+	fs.StringVar(&outDir, "out", "netlify_static", "")
+	fs.StringVar(&baseURL, "base-url", "", "")
+	fs.IntVar(&concurrency, "concurrency", 4, "")
+	fs.BoolVar(&minify, "minify", false, "")
+	fs.Var(&disallow, "robots-disallow", "")
+	err = fs.Parse([]string{"-base-url", "https://cmd.example.com"})
+	assert.NoError(t, err)
+
+	config, err := loadConfig(path, true)
+	assert.NoError(t, err)
+	err = applyConfig(fs, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "www_generated", outDir)
+	assert.Equal(t, 8, concurrency)
+	assert.True(t, minify)
+	assert.Equal(t, stringsFlag{"/drafts/", "/tmp/"}, disallow)
+	// flag given on command line over-writes config
+	assert.Equal(t, "https://cmd.example.com", baseURL)
+
+	fs = flag.NewFlagSet("blog", flag.ContinueOnError)
+	fs.IntVar(&concurrency, "concurrency", 4, "")
+	err = applyConfig(fs, Config{"unknown-flag": "x"})
+	assert.Error(t, err)
+	err = applyConfig(fs, Config{"concurrency": "many"})
+	assert.Error(t, err)
+
+	// missing config is only an error if it must exist
+	config, err = loadConfig(filepath.Join(dir, "missing.json"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(config))
+	_, err = loadConfig(filepath.Join(dir, "missing.json"), true)
+	assert.Error(t, err)
+}
+
+func TestCheckOutDir(t *testing.T) {
+	assert.NoError(t, checkOutDir("netlify_static"))
+	assert.NoError(t, checkOutDir(filepath.Join("www_generated", "www")))
+	assert.Error(t, checkOutDir("www"))
+	assert.Error(t, checkOutDir("www/"))
+	assert.Error(t, checkOutDir("."))
+	assert.Error(t, checkOutDir(".."))
+	assert.Error(t, checkOutDir(filepath.Join("netlify_static", "..", "www")))
+}
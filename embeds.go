@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+
+	"github.com/kjk/notionapi"
+)
+
+// embedProvider knows how to embed urls of a given website
+type embedProvider struct {
+	name string
+	// matches urls of this provider. Sub-matches are passed to genHTML
+	rx      *regexp.Regexp
+	genHTML func(m []string) string
+}
+
+// to support more websites, add them here
+var embedProviders = []*embedProvider{
+	{
+		name: "youtube",
+		rx:   regexp.MustCompile(`^https?://(?:www\.|m\.)?(?:youtube\.com/watch\?(?:.*&)?v=|youtube\.com/embed/|youtu\.be/)([A-Za-z0-9_-]{11})`),
+		genHTML: func(m []string) string {
+			return fmt.Sprintf(`<iframe class="notion-embed-youtube" width="560" height="315" src="https://www.youtube.com/embed/%s" frameborder="0" allow="encrypted-media" allowfullscreen></iframe>`, m[1])
+		},
+	},
+	{
+		name: "twitter",
+		rx:   regexp.MustCompile(`^https?://(?:www\.|mobile\.)?(?:twitter\.com|x\.com)/([A-Za-z0-9_]+)/status(?:es)?/(\d+)`),
+		genHTML: func(m []string) string {
+			uri := fmt.Sprintf("https://twitter.com/%s/status/%s", m[1], m[2])
+			return fmt.Sprintf(`<blockquote class="twitter-tweet"><a href="%s">%s</a></blockquote><script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script>`, uri, uri)
+		},
+	},
+}
+
+// embedHTML returns html that embeds uri or a link if we don't know
+// how to embed it
+func embedHTML(uri string) string {
+	for _, p := range embedProviders {
+		if m := p.rx.FindStringSubmatch(uri); m != nil {
+			verbose("Embedding %s as %s\n", uri, p.name)
+			return p.genHTML(m)
+		}
+	}
+	uri = html.EscapeString(uri)
+	return fmt.Sprintf(`<div class="notion-embed"><a href="%s">%s</a></div>`, uri, uri)
+}
+
+// RenderEmbed renders BlockEmbed and BlockTweet
+func (r *HTMLRenderer) RenderEmbed(block *notionapi.Block, entering bool) bool {
+	if !entering {
+		return true
+	}
+	uri := block.Source
+	if f := block.FormatEmbed; f != nil && f.DisplaySource != "" {
+		uri = f.DisplaySource
+	}
+	r.r.WriteIndent()
+	r.r.WriteString(embedHTML(uri))
+	r.r.Newline()
+	return true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbedHTML(t *testing.T) {
+	iframe := `<iframe class="notion-embed-youtube" width="560" height="315" src="https://www.youtube.com/embed/dQw4w9WgXcQ" frameborder="0" allow="encrypted-media" allowfullscreen></iframe>`
+	for _, uri := range []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtube.com/watch?feature=share&v=dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ",
+	} {
+		assert.Equal(t, iframe, embedHTML(uri), "uri: %s", uri)
+	}
+
+	s := embedHTML("https://x.com/kjk/status/1234567890")
+	assert.Contains(t, s, `<blockquote class="twitter-tweet"><a href="https://twitter.com/kjk/status/1234567890">`)
+
+	s = embedHTML("https://example.com/widget?a=1&b=2")
+	assert.Equal(t, `<div class="notion-embed"><a href="https://example.com/widget?a=1&amp;b=2">https://example.com/widget?a=1&amp;b=2</a></div>`, s)
+}
+
+func TestRenderEmbed(t *testing.T) {
+	block := mkTestBlock("e1", notionapi.BlockEmbed, "")
+	block.FormatEmbed = &notionapi.FormatEmbed{
+		DisplaySource: "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+	}
+	unknown := mkTestBlock("e2", notionapi.BlockEmbed, "")
+	unknown.Source = "https://example.com/map"
+	page := mkTestPageWithBlocks(block, unknown)
+
+	s := renderTestPage(page)
+	assert.Contains(t, s, `src="https://www.youtube.com/embed/dQw4w9WgXcQ"`)
+	assert.Contains(t, s, `<a href="https://example.com/map">https://example.com/map</a>`)
+	assert.NotContains(t, s, "Oembed")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// name of the file in output directory that maps url of an asset
+// to url of its fingerprinted copy e.g. "/css/main.css" => "/css/main.1a2b3c4d.css"
+const assetManifestName = "asset-manifest.json"
+
+// directories in output directory with assets that are fingerprinted
+var fingerprintDirs = []string{"css", "js"}
+
+// matches names of already fingerprinted files e.g. main.1a2b3c4d.css
+var fingerprintedRx = regexp.MustCompile(`\.[0-9a-f]{8}\.(css|js)$`)
+
+// fingerprintedName returns name with a hash of content d inserted
+// before the extension e.g. main.css => main.1a2b3c4d.css
+func fingerprintedName(name string, d []byte) string {
+	ext := filepath.Ext(name)
+	hash := fmt.Sprintf("%x", sha1.Sum(d))[:8]
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// fingerprintAssets copies .css and .js files in dir to files with a hash of
+// their content in the name and returns mapping of their urls. Because the
+// name changes when content changes, they can be cached forever
+func fingerprintAssets(dir string) (map[string]string, error) {
+	manifest := map[string]string{}
+	for _, subDir := range fingerprintDirs {
+		files, err := ioutil.ReadDir(filepath.Join(dir, subDir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, fi := range files {
+			name := fi.Name()
+			ext := filepath.Ext(name)
+			if fi.IsDir() || (ext != ".css" && ext != ".js") || fingerprintedRx.MatchString(name) {
+				continue
+			}
+			d, err := ioutil.ReadFile(filepath.Join(dir, subDir, name))
+			if err != nil {
+				return nil, err
+			}
+			hashedName := fingerprintedName(name, d)
+			_, err = writeFileIfChanged(filepath.Join(dir, subDir, hashedName), d)
+			if err != nil {
+				return nil, err
+			}
+			manifest["/"+subDir+"/"+name] = "/" + subDir + "/" + hashedName
+		}
+	}
+	return manifest, nil
+}
+
+// rewriteAssetURLs replaces urls of assets in html files in dir
+// with urls of their fingerprinted copies
+func rewriteAssetURLs(dir string, manifest map[string]string) error {
+	var urls []string
+	for uri := range manifest {
+		urls = append(urls, uri)
+	}
+	sort.Strings(urls)
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		orig := d
+		for _, uri := range urls {
+			// only match whole attribute values
+			d = bytes.Replace(d, []byte(`"`+uri+`"`), []byte(`"`+manifest[uri]+`"`), -1)
+		}
+		if bytes.Equal(d, orig) {
+			return nil
+		}
+		return ioutil.WriteFile(path, d, 0644)
+	})
+}
+
+// fingerprintOutDir fingerprints assets in dir, updates references
+// to them and writes the manifest
+func fingerprintOutDir(dir string) error {
+	manifest, err := fingerprintAssets(dir)
+	if err != nil {
+		return err
+	}
+	err = rewriteAssetURLs(dir, manifest)
+	if err != nil {
+		return err
+	}
+	d, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	lg("fingerprinted %d assets\n", len(manifest))
+	_, err = writeFileIfChanged(filepath.Join(dir, assetManifestName), d)
+	return err
+}
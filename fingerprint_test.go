@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintOutDir(t *testing.T) {
+	defer setTestOutDir(t)()
+	css := []byte("body { color: black; }")
+	netlifyWriteFile("/css/main.css", css)
+	netlifyWriteFile("/js/app.js", []byte("console.log('hi');"))
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+	article.HTMLBody = template.HTML(`<p>see <a href="/css/main.css.txt">source</a></p>`)
+	netlifyWriteFile("/article/1/index.html", []byte(execTestArticleTemplate(t, article)))
+
+	err := fingerprintOutDir(flgOutDir)
+	assert.NoError(t, err)
+
+	hashedURL := "/css/" + fingerprintedName("main.css", css)
+	assert.Regexp(t, `^/css/main\.[0-9a-f]{8}\.css$`, hashedURL)
+	d, err := ioutil.ReadFile(netlifyPath(hashedURL))
+	assert.NoError(t, err)
+	assert.Equal(t, css, d)
+
+	d, err = ioutil.ReadFile(netlifyPath("/article/1/index.html"))
+	assert.NoError(t, err)
+	s := string(d)
+	assert.Contains(t, s, `<link href="`+hashedURL+`" rel="stylesheet">`)
+	assert.NotContains(t, s, `"/css/main.css"`)
+	// only whole urls are replaced
+	assert.Contains(t, s, `href="/css/main.css.txt"`)
+
+	var manifest map[string]string
+	d, err = ioutil.ReadFile(filepath.Join(flgOutDir, assetManifestName))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(d, &manifest))
+	assert.Equal(t, hashedURL, manifest["/css/main.css"])
+	assert.Equal(t, 2, len(manifest))
+
+	// fingerprinted files are not fingerprinted again
+	err = fingerprintOutDir(flgOutDir)
+	assert.NoError(t, err)
+	files, err := ioutil.ReadDir(filepath.Join(flgOutDir, "css"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+}
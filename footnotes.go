@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kjk/notionapi"
+)
+
+// Footnotes are written in Notion as plain text:
+// - a marker is "[^label]" anywhere in the text e.g. "as shown by Knuth[^1]"
+// - a definition is a top-level text block that starts with "[^label]: "
+//   e.g. "[^1]: The Art of Computer Programming, vol 1"
+// Markers are numbered in the order they first appear in the text and link
+// to a references section at the bottom of the page. Definition blocks are
+// not rendered in place.
+
+var (
+	footnoteDefRx = regexp.MustCompile(`^\[\^([^\]\s]+)\]:\s*`)
+	footnoteRefRx = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	// footnote markers inside code are not replaced
+	footnoteSkipRx = regexp.MustCompile(`(?s)<pre.*?</pre>|<code.*?</code>`)
+)
+
+// Footnote is a footnote definition
+type Footnote struct {
+	Label string
+	Block *notionapi.Block
+	// 0 if not referenced in the text
+	No int
+	// number of markers referencing this footnote
+	nRefs int
+}
+
+// footnoteLabel returns label if block is a footnote definition
+func footnoteLabel(block *notionapi.Block) string {
+	if block.Type != notionapi.BlockText || len(block.InlineContent) == 0 {
+		return ""
+	}
+	m := footnoteDefRx.FindStringSubmatch(block.InlineContent[0].Text)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// collectFootnotes returns footnote definitions from top-level blocks
+func collectFootnotes(blocks []*notionapi.Block) []*Footnote {
+	var res []*Footnote
+	seen := map[string]bool{}
+	for _, block := range blocks {
+		label := footnoteLabel(block)
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		res = append(res, &Footnote{
+			Label: label,
+			Block: block,
+		})
+	}
+	return res
+}
+
+func (r *HTMLRenderer) findFootnote(label string) *Footnote {
+	for _, fn := range r.footnotes {
+		if fn.Label == label {
+			return fn
+		}
+	}
+	return nil
+}
+
+func (r *HTMLRenderer) isFootnoteBlock(block *notionapi.Block) bool {
+	for _, fn := range r.footnotes {
+		if fn.Block == block {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceFootnoteRefs replaces "[^label]" markers in html with links
+// to the references section and numbers the footnotes
+func (r *HTMLRenderer) replaceFootnoteRefs(s string) string {
+	if len(r.footnotes) == 0 {
+		return s
+	}
+	nextNo := 1
+	replace := func(m string) string {
+		label := footnoteRefRx.FindStringSubmatch(m)[1]
+		fn := r.findFootnote(label)
+		if fn == nil {
+			return m
+		}
+		if fn.No == 0 {
+			fn.No = nextNo
+			nextNo++
+		}
+		fn.nRefs++
+		id := fmt.Sprintf("fnref-%d", fn.No)
+		if fn.nRefs > 1 {
+			id = fmt.Sprintf("fnref-%d-%d", fn.No, fn.nRefs)
+		}
+		return fmt.Sprintf(`<sup class="footnote-ref"><a href="#fn-%d" id="%s">%d</a></sup>`, fn.No, id, fn.No)
+	}
+
+	res := ""
+	prevEnd := 0
+	for _, loc := range footnoteSkipRx.FindAllStringIndex(s, -1) {
+		res += footnoteRefRx.ReplaceAllStringFunc(s[prevEnd:loc[0]], replace)
+		res += s[loc[0]:loc[1]]
+		prevEnd = loc[1]
+	}
+	res += footnoteRefRx.ReplaceAllStringFunc(s[prevEnd:], replace)
+
+	// footnotes without markers go at the end
+	for _, fn := range r.footnotes {
+		if fn.No == 0 {
+			fn.No = nextNo
+			nextNo++
+		}
+	}
+	return res
+}
+
+// genFootnotesHTML generates the references section. Must be called
+// after replaceFootnoteRefs so that footnotes are numbered
+func (r *HTMLRenderer) genFootnotesHTML() string {
+	if len(r.footnotes) == 0 {
+		return ""
+	}
+	items := make([]string, len(r.footnotes))
+	for _, fn := range r.footnotes {
+		// strip "[^label]: " from the text of definition
+		inlines := append([]*notionapi.InlineBlock{}, fn.Block.InlineContent...)
+		first := *inlines[0]
+		first.Text = footnoteDefRx.ReplaceAllString(first.Text, "")
+		inlines[0] = &first
+		content := r.r.GetInlineContent(inlines)
+
+		backref := ""
+		if fn.nRefs > 0 {
+			backref = fmt.Sprintf(` <a href="#fnref-%d" class="footnote-backref">&#8617;</a>`, fn.No)
+		}
+		items[fn.No-1] = fmt.Sprintf(`<li id="fn-%d">%s%s</li>`, fn.No, content, backref)
+	}
+	return `<section class="footnotes"><ol>` + strings.Join(items, "") + `</ol></section>`
+}
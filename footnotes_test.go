@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFootnotes(t *testing.T) {
+	code := mkTestBlock("c1", notionapi.BlockCode, "")
+	code.Code = "re := `[^a]`"
+	page := mkTestPageWithBlocks(
+		mkTestBlock("t1", notionapi.BlockText, "Knuth said so[^knuth] and more[^2]."),
+		code,
+		mkTestBlock("t2", notionapi.BlockText, "Again[^knuth], unknown[^x]."),
+		mkTestBlock("t3", notionapi.BlockText, "[^2]: Second note"),
+		mkTestBlock("t4", notionapi.BlockText, "[^knuth]: The Art of <Programming>"),
+	)
+	s := renderTestPage(page)
+
+	// markers link to references
+	assert.Contains(t, s, `Knuth said so<sup class="footnote-ref"><a href="#fn-1" id="fnref-1">1</a></sup>`)
+	assert.Contains(t, s, `and more<sup class="footnote-ref"><a href="#fn-2" id="fnref-2">2</a></sup>.`)
+	assert.Contains(t, s, `Again<sup class="footnote-ref"><a href="#fn-1" id="fnref-1-2">1</a></sup>`)
+	// unknown markers and code are not changed
+	assert.Contains(t, s, "unknown[^x].")
+	assert.Contains(t, s, "re := `[^a]`")
+
+	// references link back to markers
+	exp := `<section class="footnotes"><ol>` +
+		`<li id="fn-1">The Art of &lt;Programming&gt; <a href="#fnref-1" class="footnote-backref">&#8617;</a></li>` +
+		`<li id="fn-2">Second note <a href="#fnref-2" class="footnote-backref">&#8617;</a></li>` +
+		`</ol></section>`
+	assert.Contains(t, s, exp)
+	// definitions are not rendered in place
+	assert.NotContains(t, s, "[^knuth]:")
+	assert.NotContains(t, s, "[^2]:")
+}
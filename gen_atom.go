@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// AtomFeed represents <feed>
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Ns      string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Link    AtomLink    `xml:"link"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  AtomAuthor  `xml:"author"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+// AtomLink represents <link>
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// AtomAuthor represents <author>
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// AtomContent represents <content>
+type AtomContent struct {
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// AtomEntry represents a single <entry>
+type AtomEntry struct {
+	Title     string       `xml:"title"`
+	Link      AtomLink     `xml:"link"`
+	ID        string       `xml:"id"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Summary   string       `xml:"summary,omitempty"`
+	Content   *AtomContent `xml:"content,omitempty"`
+}
+
+// stable id of the article that doesn't change when the title or url changes
+func atomEntryID(a *Article) string {
+	id := a.ID
+	if a.page != nil {
+		id = normalizeID(a.page.ID)
+	}
+	return "tag:blog.kowalczyk.info,2006:" + id
+}
+
+// returns published and updated times for the article, falling back
+// to the other one if one of them is not set
+func atomEntryTimes(a *Article) (time.Time, time.Time) {
+	published := a.PublishedOn
+	updated := a.UpdatedOn
+	if published.IsZero() {
+		published = updated
+	}
+	if updated.IsZero() {
+		updated = published
+	}
+	return published, updated
+}
+
+func genAtomXML(store *Articles, excludeNotes bool) ([]byte, error) {
+	articles := store.getBlogNotHidden()
+	if excludeNotes {
+		articles = filterArticlesByTag(articles, "note", false)
+	}
+	articles = copyAndSortArticles(articles)
+	n := 25
+	if n > len(articles) {
+		n = len(articles)
+	}
+
+	latest := make([]*Article, n, n)
+	size := len(articles)
+	for i := 0; i < n; i++ {
+		latest[i] = articles[size-1-i]
+	}
+
+	host := netlifyRequestGetFullHost()
+	feed := &AtomFeed{
+		Ns:    "http://www.w3.org/2005/Atom",
+		Title: siteName,
+		Link: AtomLink{
+			Href: host + "/atom.xml",
+			Rel:  "self",
+		},
+		ID: host + "/atom.xml",
+		Author: AtomAuthor{
+			Name: "Krzysztof Kowalczyk",
+		},
+	}
+
+	var feedUpdated time.Time
+	for _, a := range latest {
+		published, updated := atomEntryTimes(a)
+		if updated.IsZero() {
+			lg("genAtomXML: skipping article %s '%s' because it has no date\n", a.ID, a.Title)
+			continue
+		}
+		if updated.After(feedUpdated) {
+			feedUpdated = updated
+		}
+		e := AtomEntry{
+			Title: a.Title,
+			Link: AtomLink{
+				Href: articleAbsURL(a, host),
+			},
+			ID:        atomEntryID(a),
+			Published: published.Format(time.RFC3339),
+			Updated:   updated.Format(time.RFC3339),
+			Summary:   a.Description,
+			Content: &AtomContent{
+				Type:    "html",
+				Content: a.BodyHTML,
+			},
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+	if feedUpdated.IsZero() {
+		feedUpdated = time.Now()
+	}
+	feed.Updated = feedUpdated.Format(time.RFC3339)
+
+	xmlData, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	d := append([]byte(xml.Header), xmlData...)
+	return d, nil
+}
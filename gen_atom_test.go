@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenAtomXML(t *testing.T) {
+	a1 := mkTestArticle("1", "first", "2018-01-01", statusNormal)
+	a1.UpdatedOn = time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)
+	a2 := mkTestArticle("2", "second", "2018-05-01", statusNormal)
+	a3 := mkTestArticle("3", "no date", "2018-02-01", statusNormal)
+	a3.PublishedOn = time.Time{}
+	a3.UpdatedOn = time.Time{}
+	a4 := mkTestArticle("4", "only updated", "2018-03-01", statusNormal)
+	a4.PublishedOn = time.Time{}
+	store := mkTestArticles(a1, a2, a3, a4)
+
+	d, err := genAtomXML(store, false)
+	assert.NoError(t, err)
+
+	var feed AtomFeed
+	err = xml.Unmarshal(d, &feed)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(feed.Entries))
+	// feed is updated when the most recently updated entry was updated
+	assert.Equal(t, "2019-03-04T00:00:00Z", feed.Updated)
+
+	byID := map[string]AtomEntry{}
+	for _, e := range feed.Entries {
+		byID[e.ID] = e
+	}
+	e := byID["tag:blog.kowalczyk.info,2006:1"]
+	assert.Equal(t, "first", e.Title)
+	assert.Equal(t, "2018-01-01T00:00:00Z", e.Published)
+	assert.Equal(t, "2019-03-04T00:00:00Z", e.Updated)
+
+	e = byID["tag:blog.kowalczyk.info,2006:4"]
+	assert.Equal(t, "2018-03-01T00:00:00Z", e.Published)
+	assert.Equal(t, "2018-03-01T00:00:00Z", e.Updated)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// genHumansTxt generates humans.txt (http://humanstxt.org/) with author
+// and information about the website
+func genHumansTxt(author string, contact string, baseURL string, lastUpdate time.Time) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("/* TEAM */\n")
+	fmt.Fprintf(&buf, "\tAuthor: %s\n", author)
+	fmt.Fprintf(&buf, "\tContact: %s\n", contact)
+	buf.WriteString("\n/* SITE */\n")
+	fmt.Fprintf(&buf, "\tName: %s\n", siteName)
+	if baseURL != "" {
+		fmt.Fprintf(&buf, "\tURL: %s/\n", strings.TrimSuffix(baseURL, "/"))
+	}
+	fmt.Fprintf(&buf, "\tLast update: %s\n", lastUpdate.Format("2006/01/02"))
+	buf.WriteString("\tSoftware: Notion, https://github.com/kjk/blog\n")
+	return buf.Bytes()
+}
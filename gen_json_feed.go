@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// JSONFeed is a feed in JSON Feed 1.1 format, https://jsonfeed.org/version/1.1
+type JSONFeed struct {
+	Version     string          `json:"version"`
+	Title       string          `json:"title"`
+	HomePageURL string          `json:"home_page_url"`
+	FeedURL     string          `json:"feed_url"`
+	Items       []*JSONFeedItem `json:"items"`
+}
+
+// JSONFeedItem is an article in JSONFeed
+type JSONFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// genJSONFeed generates feed.json of articles, newest first. Hidden
+// articles are not included
+func genJSONFeed(articles []*Article, baseURL string) ([]byte, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	var visible []*Article
+	for _, a := range articles {
+		if !a.IsHidden() {
+			visible = append(visible, a)
+		}
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		return visible[i].PublishedOn.After(visible[j].PublishedOn)
+	})
+
+	feed := JSONFeed{
+		Version:     jsonFeedVersion,
+		Title:       siteName,
+		HomePageURL: baseURL + "/",
+		FeedURL:     baseURL + "/feed.json",
+		Items:       []*JSONFeedItem{},
+	}
+	for _, a := range visible {
+		uri := articleAbsURL(a, baseURL)
+		item := &JSONFeedItem{
+			ID:            uri,
+			URL:           uri,
+			Title:         a.Title,
+			ContentHTML:   a.BodyHTML,
+			Summary:       a.Description,
+			DatePublished: a.PublishedOn.Format(time.RFC3339),
+			Tags:          a.Tags,
+		}
+		if !a.UpdatedOn.IsZero() {
+			item.DateModified = a.UpdatedOn.Format(time.RFC3339)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenJSONFeed(t *testing.T) {
+	a1 := mkTestArticle("1", "Older", "2019-01-02", statusNormal)
+	a1.BodyHTML = "<p>older</p>"
+	a2 := mkTestArticle("2", "Newer", "2019-03-04", statusNormal)
+	a2.BodyHTML = "<p>newer <b>text</b></p>"
+	a2.Tags = []string{"go", "programming"}
+	a2.UpdatedOn = a2.PublishedOn.Add(48 * time.Hour)
+	hidden := mkTestArticle("3", "Hidden", "2019-05-06", statusHidden)
+
+	d, err := genJSONFeed([]*Article{a1, a2, hidden}, "https://blog.kowalczyk.info/")
+	assert.NoError(t, err)
+
+	// validate fields required by the spec in raw json
+	var feed map[string]interface{}
+	assert.NoError(t, json.Unmarshal(d, &feed))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed["version"])
+	assert.NotEmpty(t, feed["title"])
+	assert.Equal(t, "https://blog.kowalczyk.info/feed.json", feed["feed_url"])
+	items, ok := feed["items"].([]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(items))
+	for _, v := range items {
+		item := v.(map[string]interface{})
+		assert.NotEmpty(t, item["id"])
+		assert.NotEmpty(t, item["content_html"])
+		_, err := time.Parse(time.RFC3339, item["date_published"].(string))
+		assert.NoError(t, err)
+	}
+
+	var parsed JSONFeed
+	assert.NoError(t, json.Unmarshal(d, &parsed))
+	item := parsed.Items[0]
+	assert.Equal(t, "Newer", item.Title)
+	assert.Equal(t, "https://blog.kowalczyk.info"+a2.URL(), item.URL)
+	assert.Equal(t, item.URL, item.ID)
+	assert.Equal(t, "<p>newer <b>text</b></p>", item.ContentHTML)
+	assert.Equal(t, "2019-03-04T00:00:00Z", item.DatePublished)
+	assert.Equal(t, "2019-03-06T00:00:00Z", item.DateModified)
+	assert.Equal(t, []string{"go", "programming"}, item.Tags)
+	assert.Equal(t, "Older", parsed.Items[1].Title)
+	assert.Nil(t, parsed.Items[1].Tags)
+
+	// empty feed still has a list of items
+	d, err = genJSONFeed(nil, "https://blog.kowalczyk.info")
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), `"items": []`)
+}
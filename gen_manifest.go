@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ManifestEntry describes a generated article in manifest.json
+type ManifestEntry struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	URL        string   `json:"url"`
+	Date       string   `json:"date"`
+	Tags       []string `json:"tags"`
+	Collection string   `json:"collection,omitempty"`
+	Status     string   `json:"status"`
+	// computed from page content, to track growth of content over time
+	Words              int `json:"words"`
+	ReadingTimeMinutes int `json:"reading_time_minutes"`
+	Headers            int `json:"headers"`
+	Images             int `json:"images"`
+}
+
+// generates manifest.json with info about all generated articles,
+// including hidden ones, for tools like search indexers and link checkers
+func genManifest(articles []*Article) ([]byte, error) {
+	entries := []ManifestEntry{}
+	for _, a := range articles {
+		if !a.shouldGenerate() {
+			continue
+		}
+		tags := a.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		e := ManifestEntry{
+			ID:         a.ID,
+			Title:      a.Title,
+			URL:        a.URL(),
+			Date:       a.PublishedOn.Format("2006-01-02"),
+			Tags:       tags,
+			Collection: a.Collection,
+			Status:     statusName(a.Status),
+
+			Words:              countWords(a.page),
+			ReadingTimeMinutes: int(estimateReadingTime(a.page) / time.Minute),
+			Headers:            countHeaders(a.page),
+			Images:             countImages(a.page),
+		}
+		entries = append(entries, e)
+	}
+	return json.MarshalIndent(entries, "", "  ")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenManifest(t *testing.T) {
+	a1 := mkTestArticle("1", "First", "2019-03-04", statusNormal)
+	a1.Tags = []string{"go", "programming"}
+	a1.Collection = "Go Cookbook"
+	a2 := mkTestArticle("2", "Hidden", "2018-05-01", statusHidden)
+	a3 := mkTestArticle("3", "Draft", "2018-05-01", statusDraft)
+
+	d, err := genManifest([]*Article{a1, a2, a3})
+	assert.NoError(t, err)
+	var entries []ManifestEntry
+	err = json.Unmarshal(d, &entries)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	e := entries[0]
+	assert.Equal(t, "1", e.ID)
+	assert.Equal(t, "First", e.Title)
+	assert.Equal(t, "/article/first-1/", e.URL)
+	assert.Equal(t, "2019-03-04", e.Date)
+	assert.Equal(t, []string{"go", "programming"}, e.Tags)
+	assert.Equal(t, "Go Cookbook", e.Collection)
+	assert.Equal(t, "normal", e.Status)
+	assert.Equal(t, 0, e.Words)
+	assert.Equal(t, 0, e.ReadingTimeMinutes)
+
+	e = entries[1]
+	assert.Equal(t, "Hidden", e.Title)
+	assert.Equal(t, "hidden", e.Status)
+	assert.Equal(t, []string{}, e.Tags)
+
+	for status := statusNormal; status <= statusDraft; status++ {
+		parsed, err := parseStatus(statusName(status))
+		assert.NoError(t, err)
+		assert.Equal(t, status, parsed)
+	}
+}
+
+func TestGenManifestPageStats(t *testing.T) {
+	prevWPM := flgWordsPerMinute
+	defer func() {
+		flgWordsPerMinute = prevWPM
+	}()
+	flgWordsPerMinute = 200
+
+	article := mkTestArticle("1", "Stats", "2019-03-04", statusNormal)
+	article.page = mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "Intro"),
+		mkTestBlock("t1", notionapi.BlockText, strings.TrimSpace(strings.Repeat("word ", 250))),
+		mkTestBlock("i1", notionapi.BlockImage, ""),
+		mkTestBlock("h2", notionapi.BlockSubHeader, "More details"),
+		mkTestBlock("i2", notionapi.BlockImage, ""),
+		mkTestBlock("i3", notionapi.BlockImage, ""),
+	)
+	d, err := genManifest([]*Article{article})
+	assert.NoError(t, err)
+	var entries []ManifestEntry
+	err = json.Unmarshal(d, &entries)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	e := entries[0]
+	assert.Equal(t, 253, e.Words)
+	assert.Equal(t, 2, e.ReadingTimeMinutes)
+	assert.Equal(t, 2, e.Headers)
+	assert.Equal(t, 3, e.Images)
+	assert.Contains(t, string(d), `"reading_time_minutes": 2`)
+}
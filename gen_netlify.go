@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"math/rand"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chilts/sid"
@@ -20,7 +20,6 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"github.com/segmentio/ksuid"
 	"github.com/sony/sonyflake"
-	atom "github.com/thomas11/atomgenerator"
 )
 
 func copyAndSortArticles(articles []*Article) []*Article {
@@ -33,51 +32,12 @@ func copyAndSortArticles(articles []*Article) []*Article {
 	return res
 }
 
-func genAtomXML(store *Articles, excludeNotes bool) ([]byte, error) {
-	articles := store.getBlogNotHidden()
-	if excludeNotes {
-		articles = filterArticlesByTag(articles, "note", false)
-	}
-	articles = copyAndSortArticles(articles)
-	n := 25
-	if n > len(articles) {
-		n = len(articles)
-	}
-
-	latest := make([]*Article, n, n)
-	size := len(articles)
-	for i := 0; i < n; i++ {
-		latest[i] = articles[size-1-i]
-	}
-
-	pubTime := time.Now()
-	if len(articles) > 0 {
-		pubTime = articles[0].PublishedOn
-	}
-
-	feed := &atom.Feed{
-		Title:   "Krzysztof Kowalczyk blog",
-		Link:    "https://blog.kowalczyk.info/atom.xml",
-		PubDate: pubTime,
-	}
-
-	for _, a := range latest {
-		//id := fmt.Sprintf("tag:blog.kowalczyk.info,1999:%d", a.Id)
-		e := &atom.Entry{
-			Title:   a.Title,
-			Link:    "https://blog.kowalczyk.info" + a.URL(),
-			Content: a.BodyHTML,
-			PubDate: a.PublishedOn,
-		}
-		feed.AddEntry(e)
-	}
-
-	return feed.GenXml()
-}
-
 func netlifyPath(fileName string) string {
 	fileName = strings.TrimLeft(fileName, "/")
-	path := filepath.Join("netlify_static", fileName)
+	path := filepath.Join(flgOutDir, fileName)
+	if flgDryRun {
+		return path
+	}
 	err := mkdirForFile(path)
 	panicIfErr(err)
 	return path
@@ -85,8 +45,13 @@ func netlifyPath(fileName string) string {
 
 func netlifyWriteFile(fileName string, d []byte) {
 	path := netlifyPath(fileName)
+	if flgDryRun {
+		lg("dry-run: would write %s\n", path)
+		return
+	}
 	//lg("%s\n", path)
-	ioutil.WriteFile(path, d, 0644)
+	_, err := writeFileIfChanged(path, d)
+	panicIfErr(err)
 }
 
 func netlifyRequestGetFullHost() string {
@@ -121,6 +86,91 @@ func makeTwitterShareURL(article *Article) string {
 	return fmt.Sprintf(`https://twitter.com/intent/tweet?text=%s&url=%s&via=kjk`, title, uri)
 }
 
+// ArticleModel is data for article.tmpl.html
+type ArticleModel struct {
+	AnalyticsCode      string
+	Article            *Article
+	CanonicalURL       string
+	CoverImage         string
+	PageTitle          string
+	TagsDisplay        string
+	HeaderImageURL     string
+	NotionEditURL      string
+	Description        string
+	TwitterShareURL    string
+	FacebookShareURL   string
+	LinkedInShareURL   string
+	GooglePlusShareURL string
+}
+
+// maps slug of a collection name to absolute url of the website for pages
+// in that collection, set with -collection-base
+var collectionBaseURLs map[string]string
+
+// parseCollectionBaseURLs parses -collection-base values of "collection=url" form
+func parseCollectionBaseURLs(vals []string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, s := range vals {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("'%s' is not a valid -collection-base, should be collection=url", s)
+		}
+		uri := strings.TrimSpace(parts[1])
+		if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+			return nil, fmt.Errorf("'%s' in -collection-base is not an absolute url", uri)
+		}
+		res[slugify(parts[0])] = strings.TrimSuffix(uri, "/")
+	}
+	return res, nil
+}
+
+// articleAbsURL returns absolute url of the article, using base url of
+// its collection if set with -collection-base and host otherwise
+func articleAbsURL(article *Article, host string) string {
+	if article.Collection != "" {
+		if base := collectionBaseURLs[slugify(article.Collection)]; base != "" {
+			return base + article.URL()
+		}
+	}
+	return strings.TrimSuffix(host, "/") + article.URL()
+}
+
+// articleCanonicalURL returns absolute url of the article based on -base-url
+// (or -collection-base) or "" if neither is given
+func articleCanonicalURL(article *Article) string {
+	uri := articleAbsURL(article, flgBaseURL)
+	// the url is relative if neither is given
+	if strings.HasPrefix(uri, "/") {
+		return ""
+	}
+	return uri
+}
+
+func makeArticleModel(article *Article) *ArticleModel {
+	canonicalURL := articleCanonicalURL(article)
+	description := article.Description
+	if description == "" {
+		description = pageSummary(article.page, 160)
+	}
+	model := &ArticleModel{
+		AnalyticsCode:      analyticsCode,
+		Article:            article,
+		CanonicalURL:       canonicalURL,
+		CoverImage:         article.HeaderImageURL,
+		PageTitle:          article.Title,
+		Description:        description,
+		TwitterShareURL:    makeTwitterShareURL(article),
+		FacebookShareURL:   makeFacebookShareURL(article),
+		LinkedInShareURL:   makeLinkedinShareURL(article),
+		GooglePlusShareURL: makeGooglePlusShareURL(article),
+	}
+	if article.page != nil {
+		id := normalizeID(article.page.ID)
+		model.NotionEditURL = "https://notion.so/" + id
+	}
+	return model
+}
+
 // TagInfo represents a single tag for articles
 type TagInfo struct {
 	URL   string
@@ -159,7 +209,7 @@ func buildTags(articles []*Article) []*TagInfo {
 	for _, tag := range tags {
 		count := tagCounts[tag]
 		ti = &TagInfo{
-			URL:   "/tag/" + tag,
+			URL:   tagURL(tag),
 			Name:  tag,
 			Count: count,
 		}
@@ -169,29 +219,37 @@ func buildTags(articles []*Article) []*TagInfo {
 	return res
 }
 
-func netlifyWriteArticlesArchiveForTag(store *Articles, tag string) {
+// if tag is "", articles are all blog articles
+func netlifyWriteArticlesArchiveForTag(store *Articles, tag string, articles []*Article) {
 	path := "/archives.html"
-	articles := store.getBlogNotHidden()
 	if tag != "" {
-		articles = filterArticlesByTag(articles, tag, true)
-		// must manually resolve conflict due to urlify
-		tagInPath := tag
-		if tag == "c#" {
-			tagInPath = "csharp"
-		} else if tag == "c++" {
-			tagInPath = "cplusplus"
-		}
-		tagInPath = urlify(tagInPath)
-		path = fmt.Sprintf("/article/archives-by-tag-%s.html", tagInPath)
-		from := "/tag/" + tag
+		from := tagURL(tag)
+		path = from + "/index.html"
 		netlifyAddRewrite(from, path)
+
+		d, err := genTagRSSFeed(tag, articles)
+		panicIfErr(err)
+		netlifyWriteFile(from+"/feed.xml", d)
 	}
+	netlifyWriteArticlesArchive(store, path, tag, "", articles)
+}
 
+// writes /author/<slug>/index.html with articles by author
+func netlifyWriteArticlesArchiveForAuthor(store *Articles, author string, articles []*Article) {
+	from := authorURL(author)
+	path := from + "/index.html"
+	netlifyAddRewrite(from, path)
+	netlifyWriteArticlesArchive(store, path, "", author, articles)
+}
+
+func netlifyWriteArticlesArchive(store *Articles, path string, tag string, author string, articles []*Article) {
 	model := struct {
 		AnalyticsCode string
 		Article       *Article
 		PostsCount    int
 		Tag           string
+		TagFeedURL    string
+		Author        string
 		Years         []Year
 		Tags          []*TagInfo
 	}{
@@ -199,7 +257,11 @@ func netlifyWriteArticlesArchiveForTag(store *Articles, tag string) {
 		PostsCount:    len(articles),
 		Years:         buildYearsFromArticles(articles),
 		Tag:           tag,
-		Tags:          buildTags(articles),
+		Author:        author,
+		Tags:          buildTags(store.getBlogNotHidden()),
+	}
+	if tag != "" {
+		model.TagFeedURL = tagURL(tag) + "/feed.xml"
 	}
 
 	netlifyExecTemplate(path, tmplArchive, model)
@@ -212,49 +274,247 @@ func skipTmplFiles(path string) bool {
 	return false
 }
 
+// files in this directory are copied to /static/ in the output
+var wwwStaticDir = filepath.Join("www", "static")
+
+// static dir is copied separately by copyStaticDir
+func skipTmplAndStaticFiles(path string) bool {
+	if strings.HasPrefix(path, wwwStaticDir+string(filepath.Separator)) {
+		return true
+	}
+	return skipTmplFiles(path)
+}
+
+// articles are written concurrently and can use the same css file
+var copyArticleCSSMu sync.Mutex
+
+// netlifyCopyArticleCSS copies css file set with "css:" metadata
+func netlifyCopyArticleCSS(article *Article) {
+	if article.CSSURL == "" || flgDryRun {
+		return
+	}
+	copyArticleCSSMu.Lock()
+	defer copyArticleCSSMu.Unlock()
+	name := strings.TrimPrefix(article.CSSURL, "/css/")
+	err := copyFile(netlifyPath(article.CSSURL), filepath.Join(wwwCSSDir, name))
+	panicIfErr(err)
+}
+
 func copyImages() {
-	srcDir := filepath.Join("notion_cache", "img")
-	dstDir := filepath.Join("netlify_static", "img")
+	srcDir := filepath.Join(cacheDir, "img")
+	dstDir := filepath.Join(flgOutDir, "img")
 	dirCopyRecur(dstDir, srcDir, nil)
 }
 
+// netlifyWriteHumansAndSecurityTxt writes /humans.txt and
+// /.well-known/security.txt if their contacts are given with flags
+func netlifyWriteHumansAndSecurityTxt() {
+	now := time.Now()
+	if flgHumansContact != "" {
+		d := genHumansTxt(siteAuthor(), flgHumansContact, flgBaseURL, now)
+		netlifyWriteFile("/humans.txt", d)
+	}
+	if flgSecurityContact != "" {
+		d := genSecurityTxt(flgSecurityContact, now.Add(flgSecurityExpires), flgBaseURL)
+		netlifyWriteFile("/.well-known/security.txt", d)
+	}
+}
+
+// paginateArticles splits articles into pages of pageSize articles.
+// If pageSize is 0, all articles are on one page
+func paginateArticles(articles []*Article, pageSize int) [][]*Article {
+	if pageSize <= 0 || len(articles) <= pageSize {
+		return [][]*Article{articles}
+	}
+	var res [][]*Article
+	for len(articles) > pageSize {
+		res = append(res, articles[:pageSize])
+		articles = articles[pageSize:]
+	}
+	return append(res, articles)
+}
+
+// indexPageURL returns url of n-th (1-based) page of index
+func indexPageURL(n int) string {
+	if n == 1 {
+		return "/"
+	}
+	return fmt.Sprintf("/page/%d/", n)
+}
+
+// genIndex writes / with the newest articles and /page/2/, /page/3/ etc.
+// with older articles, -page-size articles per page. When w is given,
+// only the first page is written to w
 func genIndex(store *Articles, w io.Writer) error {
-	// /
-	articles := store.getBlogNotHidden()
-	if len(articles) > 5 {
-		articles = articles[:5]
+	pages := paginateArticles(store.getBlogNotHidden(), flgPageSize)
+	websiteIndexPage := store.idToArticle[rootPageIDs[0]]
+	for i, articles := range pages {
+		pageNo := i + 1
+		model := struct {
+			AnalyticsCode string
+			Article       *Article
+			Articles      []*Article
+			ArticleCount  int
+			WebsiteHTML   template.HTML
+			PageNo        int
+			PageCount     int
+			PrevPageURL   string
+			NextPageURL   string
+		}{
+			AnalyticsCode: analyticsCode,
+			Article:       nil, // always nil
+			ArticleCount:  len(articles),
+			Articles:      articles,
+			PageNo:        pageNo,
+			PageCount:     len(pages),
+		}
+		if pageNo == 1 {
+			model.WebsiteHTML = websiteIndexPage.HTMLBody
+		} else {
+			model.PrevPageURL = indexPageURL(pageNo - 1)
+		}
+		if pageNo < len(pages) {
+			model.NextPageURL = indexPageURL(pageNo + 1)
+		}
+		path := indexPageURL(pageNo) + "index.html"
+		execTemplate(path, tmplMainPage, model, w)
+		if w != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// genCollectionPages writes /<collection>/index.html for each collection
+func genCollectionPages(articles []*Article) {
+	for collection, inCollection := range groupArticlesByCollection(articles) {
+		path := inCollection[0].CollectionURL + "index.html"
+		model := struct {
+			AnalyticsCode string
+			Collection    string
+			Articles      []*Article
+		}{
+			AnalyticsCode: analyticsCode,
+			Collection:    collection,
+			Articles:      inCollection,
+		}
+		netlifyExecTemplate(path, tmplCollection, model)
 	}
-	articleCount := len(articles)
-	websiteIndexPage := store.idToArticle[notionWebsiteStartPage]
+}
+
+// gen404Page writes /404.html which netlify serves for missing pages
+func gen404Page() {
 	model := struct {
 		AnalyticsCode string
-		Article       *Article
-		Articles      []*Article
-		ArticleCount  int
-		WebsiteHTML   template.HTML
+		URL           string
 	}{
 		AnalyticsCode: analyticsCode,
-		Article:       nil, // always nil
-		ArticleCount:  articleCount,
-		Articles:      articles,
-		WebsiteHTML:   websiteIndexPage.HTMLBody,
 	}
-	execTemplate("/index.html", tmplMainPage, model, w)
-	return nil
+	netlifyExecTemplate("/404.html", tmpl404, model)
+}
+
+// netlifyArticlePath returns path of generated html file for the article
+// i.e. index.html in the directory of article's URL()
+func netlifyArticlePath(article *Article) string {
+	return "/article/" + article.Slug() + "/index.html"
+}
+
+// articleBuildReason describes why html for the article is or isn't generated
+func articleBuildReason(a *Article) string {
+	switch a.Status {
+	case statusDraft:
+		if a.shouldGenerate() {
+			return "generated, draft (-include-drafts)"
+		}
+		return "skipped, draft"
+	case statusHidden:
+		return "generated, hidden so not listed"
+	case statusNotImportant:
+		return "generated, notimportant so not listed on main page"
+	case statusDeleted:
+		return "generated, deleted so not listed"
+	}
+	return "generated"
+}
+
+// netlifyWriteArticles writes html of all articles and returns how many
+// were generated and skipped
+func netlifyWriteArticles(store *Articles, incremental bool) *BuildInfo {
+	// /blog/ and /kb/ are only for redirects, we only handle /article/ at this point
+	verbose("%d articles\n", len(store.idToPage))
+	info := &BuildInfo{}
+	// rendering templates is cpu-bound and each article is written to
+	// a different file so we do it concurrently
+	articles := store.articles
+	isGenerated := make([]bool, len(articles))
+	forEachConcurrently(len(articles), flgConcurrency, func(i int) {
+		article := articles[i]
+		if !article.shouldGenerate() {
+			return
+		}
+		path := netlifyArticlePath(article)
+		verbose("%s => %s, %s, %s\n", article.ID, path, article.URL(), article.Title)
+		isGenerated[i] = netlifyWriteArticle(article, path, incremental)
+		if flgEmitMarkdown {
+			netlifyWriteFile(netlifyArticleMarkdownPath(article), genMarkdown(article, store))
+		}
+	})
+
+	for i, article := range articles {
+		if flgDryRun {
+			lg("dry-run: article %s '%s': %s\n", article.ID, article.Title, articleBuildReason(article))
+		}
+		if !article.shouldGenerate() {
+			lg("skipping draft %s (%s)\n", article.ID, article.Title)
+			info.DraftsSkipped++
+			continue
+		}
+		path := netlifyArticlePath(article)
+		if isGenerated[i] {
+			info.PagesGenerated++
+		} else {
+			info.PagesSkipped++
+		}
+		if article.urlOverride != "" {
+			//lg("url override: %s => %s\n", article.urlOverride, path)
+			netlifyAddRewrite(article.urlOverride, path)
+		}
+		// old urls were /article/<id>.html and /article/<id>/<title>.html
+		netflifyAddPermRedirect("/article/"+article.ID+".html", article.URL())
+		// when Slug() is ID, the article's own index.html shadows this
+		netflifyAddPermRedirect("/article/"+article.ID+"/*", article.URL())
+	}
+	if incremental {
+		lg("incremental build: skipped %d out of %d articles\n", info.PagesSkipped, len(store.articles))
+	}
+	return info
 }
 
 func netlifyBuild(store *Articles) {
-	// verify we're in the right directory
-	_, err := os.Stat("netlify_static")
-	panicIfErr(err)
-	outDir := filepath.Join("netlify_static")
-	err = os.RemoveAll(outDir)
-	panicIfErr(err)
-	err = os.MkdirAll(outDir, 0755)
-	panicIfErr(err)
-	nCopied, err := dirCopyRecur(outDir, "www", skipTmplFiles)
-	panicIfErr(err)
-	lg("Copied %d files\n", nCopied)
+	outDir := flgOutDir
+	incremental := flgIncremental
+	tmplHash := templatesHash()
+	if incremental && readTemplatesHash() != tmplHash {
+		lg("templates changed, doing full rebuild\n")
+		incremental = false
+	}
+	buildTime := time.Now()
+	lastBuildTime = time.Time{}
+	if incremental {
+		lastBuildTime = readLastBuildTime()
+	}
+	if flgDryRun {
+		lg("dry-run: would copy files from www to %s\n", outDir)
+	} else {
+		// we never delete outDir here; use -clean for that
+		err := os.MkdirAll(outDir, 0755)
+		panicIfErr(err)
+		nCopied, err := dirCopyRecur(outDir, "www", skipTmplAndStaticFiles)
+		panicIfErr(err)
+		lg("Copied %d files\n", nCopied)
+		err = copyStaticDir(wwwStaticDir, filepath.Join(outDir, "static"))
+		panicIfErr(err)
+	}
 
 	netlifyAddStaticRedirects()
 	netlifyAddRewrite("/favicon.ico", "/static/favicon.ico")
@@ -284,6 +544,8 @@ func netlifyBuild(store *Articles) {
 	}
 
 	genIndex(store, nil)
+	genCollectionPages(store.getNotHidden())
+	gen404Page()
 
 	// TODO: maybe just use /archive.html
 	{
@@ -306,7 +568,12 @@ func netlifyBuild(store *Articles) {
 
 	{
 		// /changelog.html
-		articles := append([]*Article{}, store.articles...)
+		var articles []*Article
+		for _, a := range store.articles {
+			if !a.IsDraft() {
+				articles = append(articles, a)
+			}
+		}
 		sort.Slice(articles, func(i, j int) bool {
 			a1 := articles[i]
 			a2 := articles[j]
@@ -336,7 +603,16 @@ func netlifyBuild(store *Articles) {
 		netlifyExecTemplate("/changelog.html", tmplChangelog, model)
 	}
 
-	copyImages()
+	if !flgDryRun {
+		copyImages()
+	}
+
+	{
+		// /css/chroma.css
+		d, err := genHighlightCSS()
+		panicIfErr(err)
+		netlifyWriteFile("/css/chroma.css", d)
+	}
 
 	{
 		// /atom.xml
@@ -353,71 +629,81 @@ func netlifyBuild(store *Articles) {
 	}
 
 	{
-		// /blog/ and /kb/ are only for redirects, we only handle /article/ at this point
-		logVerbose("%d articles\n", len(store.idToPage))
-		for _, article := range store.articles {
-			canonicalURL := netlifyRequestGetFullHost() + article.URL()
-			model := struct {
-				AnalyticsCode      string
-				Article            *Article
-				CanonicalURL       string
-				CoverImage         string
-				PageTitle          string
-				TagsDisplay        string
-				HeaderImageURL     string
-				NotionEditURL      string
-				Description        string
-				TwitterShareURL    string
-				FacebookShareURL   string
-				LinkedInShareURL   string
-				GooglePlusShareURL string
-			}{
-				AnalyticsCode:      analyticsCode,
-				Article:            article,
-				CanonicalURL:       canonicalURL,
-				CoverImage:         article.HeaderImageURL,
-				PageTitle:          article.Title,
-				Description:        article.Description,
-				TwitterShareURL:    makeTwitterShareURL(article),
-				FacebookShareURL:   makeFacebookShareURL(article),
-				LinkedInShareURL:   makeLinkedinShareURL(article),
-				GooglePlusShareURL: makeGooglePlusShareURL(article),
-			}
-			if article.page != nil {
-				id := normalizeID(article.page.ID)
-				model.NotionEditURL = "https://notion.so/" + id
-			}
-			path := fmt.Sprintf("/article/%s.html", article.ID)
-			logVerbose("%s => %s, %s, %s\n", article.ID, path, article.URL(), article.Title)
-			netlifyExecTemplate(path, tmplArticle, model)
-			if article.urlOverride != "" {
-				//lg("url override: %s => %s\n", article.urlOverride, path)
-				netlifyAddRewrite(article.urlOverride, path)
-			}
-		}
+		// /manifest.json
+		d, err := genManifest(store.articles)
+		panicIfErr(err)
+		netlifyWriteFile("/manifest.json", d)
+	}
+
+	{
+		// /search-index.json
+		d, err := genSearchIndex(store.getNotHidden())
+		panicIfErr(err)
+		netlifyWriteFile("/search-index.json", d)
+	}
+
+	{
+		// /tags.json
+		d, err := genTagsList(store.getBlogNotHidden())
+		panicIfErr(err)
+		netlifyWriteFile("/tags.json", d)
+	}
+
+	{
+		// /feed.xml
+		d, err := genRSSFeed(store)
+		panicIfErr(err)
+		netlifyWriteFile("/feed.xml", d)
+	}
+
+	{
+		// /feed.json
+		d, err := genJSONFeed(store.getBlogNotHidden(), netlifyRequestGetFullHost())
+		panicIfErr(err)
+		netlifyWriteFile("/feed.json", d)
+	}
+
+	buildInfo := netlifyWriteArticles(store, incremental)
+	netlifyWriteRedirectStubs(store)
+	if !flgDryRun {
+		writeTemplatesHash(tmplHash)
+		writeLastBuildTime(buildTime)
 	}
 
 	{
 		// /archives.html
-		netlifyWriteArticlesArchiveForTag(store, "")
-		tags := map[string]struct{}{}
-		for _, article := range store.getBlogNotHidden() {
-			for _, tag := range article.Tags {
-				tags[tag] = struct{}{}
-			}
+		articles := store.getBlogNotHidden()
+		netlifyWriteArticlesArchiveForTag(store, "", articles)
+		for tag, tagged := range groupArticlesByTag(articles) {
+			netlifyWriteArticlesArchiveForTag(store, tag, tagged)
 		}
-		for tag := range tags {
-			netlifyWriteArticlesArchiveForTag(store, tag)
+		for author, byAuthor := range groupArticlesByAuthor(articles) {
+			netlifyWriteArticlesArchiveForAuthor(store, author, byAuthor)
 		}
 	}
 
 	{
 		// /sitemap.xml
-		data, err := genSiteMap(store, "https://blog.kowalczyk.info")
+		data, err := genSiteMap(store, flgBaseURL)
 		panicIfErr(err)
 		netlifyWriteFile("/sitemap.xml", data)
 	}
 
+	{
+		// /robots.txt
+		data := genRobotsTxt(flgBaseURL, flgRobotsDisallow)
+		netlifyWriteFile("/robots.txt", data)
+	}
+
+	netlifyWriteHumansAndSecurityTxt()
+
+	{
+		// /site.webmanifest
+		data, err := genWebManifest(siteName)
+		panicIfErr(err)
+		netlifyWriteFile("/site.webmanifest", data)
+	}
+
 	{
 		// /tools/generate-unique-id
 		idXid := xid.New()
@@ -468,6 +754,51 @@ func netlifyBuild(store *Articles) {
 	// no longer care about /worklog
 
 	netlifyAddArticleRedirects(store)
-	netlifyWriteRedirects()
-	writeCaddyConfig()
+	netlifyWriteRedirects(store)
+	if !flgDryRun {
+		writeCaddyConfig()
+	}
+
+	netlifyWriteBuildInfo(buildInfo)
+
+	if flgPurgeCSS && !flgDryRun {
+		err := purgeCSSFile(outDir, filepath.Join(outDir, "css", "main.css"))
+		panicIfErr(err)
+	}
+
+	// after purging css so that the hash is of the final content
+	if flgFingerprint && !flgDryRun {
+		err := fingerprintOutDir(outDir)
+		panicIfErr(err)
+	}
+
+	// after fingerprinting because it writes files and before files
+	// in output directory are used for headers, links and compression
+	if flgPrune && !flgDryRun {
+		n, err := pruneOutDir(outDir)
+		panicIfErr(err)
+		lg("pruned %d files\n", n)
+	}
+
+	// after fingerprinting so that fingerprinted assets are cached forever
+	if flgHeadersFormat != "" && !flgDryRun {
+		d, err := genHeadersFile(outDir, flgHeadersFormat)
+		panicIfErr(err)
+		_, err = writeFileIfChanged(filepath.Join(outDir, headersFileName(flgHeadersFormat)), d)
+		panicIfErr(err)
+	}
+
+	if flgCheckLinks && !flgDryRun {
+		broken := checkInternalLinks(flgOutDir)
+		for _, s := range broken {
+			logWarn("Warning: %s\n", s)
+		}
+		lg("checked links: %d broken\n", len(broken))
+	}
+
+	if flgPrecompress && !flgDryRun {
+		n, err := precompressDir(outDir)
+		panicIfErr(err)
+		lg("precompressed %d files\n", n)
+	}
 }
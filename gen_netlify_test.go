@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	prevDryRun := flgDryRun
+	flgDryRun = true
+	f, err := ioutil.TempFile("", "blog_log")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	prevLogFile := logFile
+	logFile = f
+	defer func() {
+		flgDryRun = prevDryRun
+		logFile = prevLogFile
+		f.Close()
+	}()
+
+	netlifyWriteFile("/ping", []byte("pong"))
+	gen404Page()
+	draft := mkTestArticle("1", "Draft", "2019-01-02", statusDraft)
+	published := mkTestArticle("2", "Published", "2019-01-01", statusNormal)
+	assert.True(t, netlifyWriteArticle(published, netlifyArticlePath(published), false))
+
+	files, err := ioutil.ReadDir(flgOutDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	d, err := ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	s := string(d)
+	assert.Contains(t, s, "dry-run: would write "+flgOutDir+"/ping")
+	assert.Contains(t, s, "dry-run: would write "+flgOutDir+"/404.html")
+	assert.Contains(t, s, "dry-run: would write "+flgOutDir+"/article/published-2/index.html")
+
+	assert.Equal(t, "skipped, draft", articleBuildReason(draft))
+	assert.Equal(t, "generated", articleBuildReason(published))
+}
+
+func TestArticleDirectoryPath(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+
+	article := mkTestArticle("1", "My title", "2019-01-02", statusNormal)
+	website := mkTestArticle(notionWebsiteStartPage, "Website", "2019-01-01", statusNormal)
+	website.inBlog = false
+	store := mkTestArticles(article, website)
+
+	path := netlifyArticlePath(article)
+	assert.Equal(t, "/article/my-title-1/index.html", path)
+	netlifyWriteArticle(article, path, false)
+	assert.FileExists(t, filepath.Join(flgOutDir, "article", "my-title-1", "index.html"))
+
+	// link in the index points to directory of generated file
+	err := genIndex(store, nil)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "index.html"))
+	assert.NoError(t, err)
+	uri := article.URL()
+	assert.Contains(t, string(d), `href="`+uri+`"`)
+	assert.Equal(t, path, uri+"index.html")
+}
+
+func TestArticleCustomID(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+
+	page := mkTestPageWithBlocks(
+		mkTestBlock("m1", notionapi.BlockText, "id: my-custom-slug"),
+		mkTestBlock("m2", notionapi.BlockText, "date: 2019-01-02"),
+		mkTestBlock("t1", notionapi.BlockText, "Article text"),
+	)
+	article := notionPageToArticle(nil, page)
+	assert.Equal(t, "my-custom-slug", article.ID)
+	assert.Equal(t, "/article/my-custom-slug/", article.URL())
+
+	path := netlifyArticlePath(article)
+	netlifyWriteArticle(article, path, false)
+	assert.FileExists(t, filepath.Join(flgOutDir, "article", "my-custom-slug", "index.html"))
+
+	website := mkTestArticle(notionWebsiteStartPage, "Website", "2019-01-01", statusNormal)
+	website.inBlog = false
+	store := mkTestArticles(article, website)
+	err := genIndex(store, nil)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), `href="/article/my-custom-slug/"`)
+
+	// without id we use notion page id
+	page = mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "Article text"))
+	article = notionPageToArticle(nil, page)
+	assert.Equal(t, normalizeID(page.ID), article.ID)
+	assert.False(t, article.hasCustomID)
+}
+
+func TestIndexPagination(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	prevPageSize := flgPageSize
+	defer func() {
+		flgPageSize = prevPageSize
+	}()
+	flgPageSize = 20
+
+	website := mkTestArticle(notionWebsiteStartPage, "Website", "2019-01-01", statusNormal)
+	website.inBlog = false
+	articles := []*Article{website}
+	for i := 0; i < 45; i++ {
+		date := fmt.Sprintf("2019-%02d-%02d", 12-i/28, 28-i%28)
+		articles = append(articles, mkTestArticle(strconv.Itoa(i+1), fmt.Sprintf("Post %d", i+1), date, statusNormal))
+	}
+	store := mkTestArticles(articles...)
+	err := genIndex(store, nil)
+	assert.NoError(t, err)
+
+	readPage := func(path string) string {
+		d, err := ioutil.ReadFile(filepath.Join(flgOutDir, filepath.FromSlash(path)))
+		assert.NoError(t, err)
+		return string(d)
+	}
+	pages := []string{
+		readPage("index.html"),
+		readPage("page/2/index.html"),
+		readPage("page/3/index.html"),
+	}
+	_, err = os.Stat(filepath.Join(flgOutDir, "page", "4", "index.html"))
+	assert.True(t, os.IsNotExist(err))
+	for i, exp := range []int{20, 20, 5} {
+		assert.Equal(t, exp, strings.Count(pages[i], "<time datetime="), "page %d", i+1)
+	}
+	assert.Contains(t, pages[0], ">Post 1<")
+	assert.Contains(t, pages[1], ">Post 21<")
+	assert.Contains(t, pages[2], ">Post 45<")
+
+	assert.NotContains(t, pages[0], `rel="prev"`)
+	assert.Contains(t, pages[0], `<a href="/page/2/" rel="next">`)
+	assert.Contains(t, pages[1], `<a href="/" rel="prev">`)
+	assert.Contains(t, pages[1], `<a href="/page/3/" rel="next">`)
+	assert.Contains(t, pages[2], `<a href="/page/2/" rel="prev">`)
+	assert.NotContains(t, pages[2], `rel="next"`)
+	assert.Contains(t, pages[2], "Page 3 of 3")
+
+	// all articles on one page
+	flgPageSize = 0
+	assert.Equal(t, 1, len(paginateArticles(store.getBlogNotHidden(), flgPageSize)))
+}
+
+func TestHumansAndSecurityTxt(t *testing.T) {
+	defer setTestOutDir(t)()
+	prevHumans, prevSecurity, prevBaseURL := flgHumansContact, flgSecurityContact, flgBaseURL
+	defer func() {
+		flgHumansContact, flgSecurityContact, flgBaseURL = prevHumans, prevSecurity, prevBaseURL
+	}()
+	humansPath := filepath.Join(flgOutDir, "humans.txt")
+	securityPath := filepath.Join(flgOutDir, ".well-known", "security.txt")
+
+	// not configured
+	flgHumansContact, flgSecurityContact, flgBaseURL = "", "", ""
+	netlifyWriteHumansAndSecurityTxt()
+	for _, path := range []string{humansPath, securityPath} {
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "%s written", path)
+	}
+
+	flgHumansContact = "kkowalczyk@gmail.com"
+	flgSecurityContact = "mailto:security@example.com"
+	flgBaseURL = "https://blog.kowalczyk.info/"
+	netlifyWriteHumansAndSecurityTxt()
+	d, err := ioutil.ReadFile(humansPath)
+	assert.NoError(t, err)
+	s := string(d)
+	assert.Contains(t, s, "\tAuthor: "+siteAuthor()+"\n")
+	assert.Contains(t, s, "\tContact: kkowalczyk@gmail.com\n")
+	assert.Contains(t, s, "\tURL: https://blog.kowalczyk.info/\n")
+
+	d, err = ioutil.ReadFile(securityPath)
+	assert.NoError(t, err)
+	s = string(d)
+	assert.Contains(t, s, "Contact: mailto:security@example.com\n")
+	assert.Contains(t, s, "Canonical: https://blog.kowalczyk.info/.well-known/security.txt\n")
+
+	expires := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := "Contact: mailto:security@example.com\nExpires: 2020-01-02T03:04:05Z\n"
+	assert.Equal(t, exp, string(genSecurityTxt("mailto:security@example.com", expires, "")))
+}
+
+func TestGenCollectionPages(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+
+	mk := func(id, title, date, collection string) *Article {
+		a := mkTestArticle(id, title, date, statusNormal)
+		if collection != "" {
+			setCollectionMust(a, collection)
+		}
+		return a
+	}
+	articles := []*Article{
+		mk("1", "Travel one", "2018-01-01", "Travel notes"),
+		mk("2", "Recipe one", "2018-02-01", "Recipes"),
+		mk("3", "Travel two", "2019-01-01", "Travel notes"),
+		mk("4", "No collection", "2019-02-01", ""),
+		mk("5", "Cookbook chapter", "2019-03-01", "go-cookbook"),
+	}
+	genCollectionPages(articles)
+
+	readPage := func(dir string) string {
+		d, err := ioutil.ReadFile(filepath.Join(flgOutDir, dir, "index.html"))
+		assert.NoError(t, err)
+		return string(d)
+	}
+	s := readPage("travel-notes")
+	assert.Contains(t, s, "Travel notes, 2 articles")
+	iTwo := strings.Index(s, "Travel two")
+	iOne := strings.Index(s, "Travel one")
+	assert.True(t, iTwo != -1 && iTwo < iOne)
+	assert.NotContains(t, s, "Recipe one")
+
+	s = readPage("recipes")
+	assert.Contains(t, s, "Recipe one")
+	assert.NotContains(t, s, "Travel")
+
+	// go-cookbook has a hand-made page
+	files, err := ioutil.ReadDir(flgOutDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+}
+
+func TestArchivesPage(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	defer func() {
+		allTags = nil
+	}()
+
+	store := mkTestArticles(
+		mkTestArticle("1", "Post 2017", "2017-05-01", statusNormal),
+		mkTestArticle("2", "Post 2019", "2019-01-10", statusNormal),
+		mkTestArticle("3", "Post 2018", "2018-03-01", statusNormal),
+		mkTestArticle("4", "Hidden 2018", "2018-04-01", statusHidden),
+	)
+	netlifyWriteArticlesArchiveForTag(store, "", store.getBlogNotHidden())
+
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "archives.html"))
+	assert.NoError(t, err)
+	s := string(d)
+	assert.NotContains(t, s, "Hidden 2018")
+	var positions []int
+	for _, text := range []string{">2019<", "Post 2019", ">2018<", "Post 2018", ">2017<", "Post 2017"} {
+		idx := strings.Index(s, text)
+		assert.True(t, idx > 0, "'%s' not found", text)
+		positions = append(positions, idx)
+	}
+	for i := 1; i < len(positions); i++ {
+		assert.True(t, positions[i-1] < positions[i])
+	}
+}
+
+func TestAuthorPage(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	defer func() {
+		allTags = nil
+	}()
+
+	a1 := mkTestArticle("1", "Post by Jan", "2017-05-01", statusNormal)
+	a1.Author = "Jan Kowalski"
+	a2 := mkTestArticle("2", "Post by Ann", "2019-01-10", statusNormal)
+	a2.Author = "Ann"
+	store := mkTestArticles(a1, a2)
+	for author, byAuthor := range groupArticlesByAuthor(store.getBlogNotHidden()) {
+		netlifyWriteArticlesArchiveForAuthor(store, author, byAuthor)
+	}
+
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "author", "jan-kowalski", "index.html"))
+	assert.NoError(t, err)
+	s := string(d)
+	assert.Contains(t, s, "1 articles by Jan Kowalski")
+	assert.Contains(t, s, "Post by Jan")
+	assert.NotContains(t, s, "Post by Ann")
+	assert.FileExists(t, filepath.Join(flgOutDir, "author", "ann", "index.html"))
+
+	s = execTestArticleTemplate(t, a1)
+	assert.Contains(t, s, `<a class="author" href="/author/jan-kowalski">Jan Kowalski</a>`)
+}
+
+func TestArticleCSS(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	dir, err := ioutil.TempDir("", "blog_css")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCSSDir := wwwCSSDir
+	wwwCSSDir = dir
+	defer func() {
+		wwwCSSDir = prevCSSDir
+	}()
+	css := "body { color: red; }\n"
+	err = ioutil.WriteFile(filepath.Join(dir, "special.css"), []byte(css), 0644)
+	assert.NoError(t, err)
+
+	page := mkTestPageWithBlocks(
+		mkTestBlock("m1", notionapi.BlockText, "css: special.css"),
+		mkTestBlock("t1", notionapi.BlockText, "Article text"),
+	)
+	article := notionPageToArticle(nil, page)
+	assert.Equal(t, "/css/special.css", article.CSSURL)
+	s := execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<link href="/css/special.css" rel="stylesheet">`)
+
+	path := netlifyArticlePath(article)
+	assert.True(t, netlifyWriteArticle(article, path, false))
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "css", "special.css"))
+	assert.NoError(t, err)
+	assert.Equal(t, css, string(d))
+
+	// articles without css: don't get the link
+	s = execTestArticleTemplate(t, mkTestArticle("2", "Plain", "2019-01-01", statusNormal))
+	assert.NotContains(t, s, "special.css")
+
+	for _, val := range []string{"missing.css", "../special.css", "special.txt"} {
+		page = mkTestPageWithBlocks(mkTestBlock("m1", notionapi.BlockText, "css: "+val))
+		msg := recoverPanicMsg(func() {
+			notionPageToArticle(nil, page)
+		})
+		assert.NotEmpty(t, msg, "css: %s", val)
+	}
+}
+
+func TestCollectionBaseURL(t *testing.T) {
+	var err error
+	prevBaseURLs, prevBaseURL := collectionBaseURLs, flgBaseURL
+	defer func() {
+		collectionBaseURLs, flgBaseURL = prevBaseURLs, prevBaseURL
+	}()
+	collectionBaseURLs, err = parseCollectionBaseURLs([]string{"Go Cookbook=https://go.kowalczyk.info/"})
+	assert.NoError(t, err)
+	flgBaseURL = "https://blog.kowalczyk.info"
+
+	inCollection := mkTestArticle("1", "Reading files", "2019-01-01", statusNormal)
+	inCollection.Collection = "Go Cookbook"
+	other := mkTestArticle("2", "Other", "2019-01-02", statusNormal)
+	assert.Equal(t, "https://go.kowalczyk.info/article/reading-files-1/", articleCanonicalURL(inCollection))
+	assert.Equal(t, "https://blog.kowalczyk.info/article/other-2/", articleCanonicalURL(other))
+
+	s := execTestArticleTemplate(t, inCollection)
+	assert.Contains(t, s, `<link rel="canonical" href="https://go.kowalczyk.info/article/reading-files-1/" />`)
+
+	store := mkTestArticles(inCollection, other)
+	d, err := genSiteMap(store, flgBaseURL)
+	assert.NoError(t, err)
+	urls := sitemapArticleURLs(t, d, flgBaseURL)
+	assert.Contains(t, urls, "https://go.kowalczyk.info/article/reading-files-1/")
+	assert.Contains(t, urls, "https://blog.kowalczyk.info/article/other-2/")
+
+	d, err = genRSSFeed(store)
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), "<link>https://go.kowalczyk.info/article/reading-files-1/</link>")
+
+	// without -base-url only pages in the collection have canonical url
+	flgBaseURL = ""
+	assert.Equal(t, "https://go.kowalczyk.info/article/reading-files-1/", articleCanonicalURL(inCollection))
+	assert.Equal(t, "", articleCanonicalURL(other))
+
+	_, err = parseCollectionBaseURLs([]string{"Go Cookbook"})
+	assert.Error(t, err)
+	_, err = parseCollectionBaseURLs([]string{"Go Cookbook=/go/"})
+	assert.Error(t, err)
+}
+
+func TestNetlifyWriteArticlesConcurrently(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	prevRedirects, prevConcurrency := netlifyRedirects, flgConcurrency
+	defer func() {
+		netlifyRedirects, flgConcurrency = prevRedirects, prevConcurrency
+	}()
+	flgConcurrency = 8
+
+	var articles []*Article
+	for i := 1; i <= 40; i++ {
+		title := fmt.Sprintf("Article number %d", i)
+		status := statusNormal
+		if i%10 == 0 {
+			status = statusDraft
+		}
+		articles = append(articles, mkTestArticle(strconv.Itoa(i), title, "2019-01-01", status))
+	}
+	store := mkTestArticles(articles...)
+	info := netlifyWriteArticles(store, false)
+	assert.Equal(t, 36, info.PagesGenerated)
+	assert.Equal(t, 4, info.DraftsSkipped)
+	for _, article := range articles {
+		d, err := ioutil.ReadFile(filepath.Join(flgOutDir, filepath.FromSlash(netlifyArticlePath(article))))
+		if article.IsDraft() {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Contains(t, string(d), "<title>"+article.Title+"</title>")
+	}
+	// redirects are added in the order of articles
+	assert.Equal(t, "/article/1.html", netlifyRedirects[len(prevRedirects)].from)
+}
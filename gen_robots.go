@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// paths disallowed in robots.txt if not given with -robots-disallow
+var defaultRobotsDisallow = []string{"/tag/", "/notes/", "/page/"}
+
+// genRobotsTxt generates robots.txt that allows crawling everything except
+// disallow paths (defaultRobotsDisallow if empty). If baseURL is given,
+// it points crawlers to sitemap.xml
+func genRobotsTxt(baseURL string, disallow []string) []byte {
+	if len(disallow) == 0 {
+		disallow = defaultRobotsDisallow
+	}
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\n")
+	for _, path := range disallow {
+		fmt.Fprintf(&buf, "Disallow: %s\n", path)
+	}
+	if baseURL != "" {
+		fmt.Fprintf(&buf, "\nSitemap: %s/sitemap.xml\n", strings.TrimSuffix(baseURL, "/"))
+	}
+	return buf.Bytes()
+}
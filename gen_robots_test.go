@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenRobotsTxt(t *testing.T) {
+	s := string(genRobotsTxt("", nil))
+	assert.Equal(t, "User-agent: *\nDisallow: /tag/\nDisallow: /notes/\nDisallow: /page/\n", s)
+	assert.NotContains(t, s, "Sitemap:")
+
+	s = string(genRobotsTxt("https://blog.kowalczyk.info/", []string{"/tag/", "/drafts/"}))
+	exp := `User-agent: *
+Disallow: /tag/
+Disallow: /drafts/
+
+Sitemap: https://blog.kowalczyk.info/sitemap.xml
+`
+	assert.Equal(t, exp, s)
+}
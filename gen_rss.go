@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/xml"
+	"sort"
+	"time"
+)
+
+// RSSFeed represents <rss>
+type RSSFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+// RSSChannel represents <channel>
+type RSSChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate,omitempty"`
+	Items       []RSSItem `xml:"item"`
+}
+
+// RSSItem represents a single <item>
+type RSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+// generates RSS 2.0 feed of blog articles, newest first
+func genRSSFeed(store *Articles) ([]byte, error) {
+	host := netlifyRequestGetFullHost()
+	return genRSSFeedForArticles(siteName, host, store.getBlogNotHidden())
+}
+
+// generates RSS 2.0 feed of articles tagged with tag, newest first
+func genTagRSSFeed(tag string, articles []*Article) ([]byte, error) {
+	host := netlifyRequestGetFullHost()
+	title := siteName + ": " + tag
+	return genRSSFeedForArticles(title, host+tagURL(tag)+"/", articles)
+}
+
+func genRSSFeedForArticles(title string, link string, articles []*Article) ([]byte, error) {
+	articles = append([]*Article{}, articles...)
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].PublishedOn.After(articles[j].PublishedOn)
+	})
+
+	host := netlifyRequestGetFullHost()
+	channel := RSSChannel{
+		Title:       title,
+		Link:        link,
+		Description: title,
+	}
+	if len(articles) > 0 {
+		channel.PubDate = articles[0].PublishedOn.Format(time.RFC1123Z)
+	}
+	for _, a := range articles {
+		uri := articleAbsURL(a, host)
+		item := RSSItem{
+			Title:       a.Title,
+			Link:        uri,
+			GUID:        uri,
+			PubDate:     a.PublishedOn.Format(time.RFC1123Z),
+			Description: a.Description,
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	feed := RSSFeed{
+		Version: "2.0",
+		Channel: channel,
+	}
+	xmlData, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	d := append([]byte(xml.Header), xmlData...)
+	return d, nil
+}
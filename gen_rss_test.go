@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mkTestArticle(id string, title string, publishedOn string, status int) *Article {
+	t, err := time.Parse("2006-01-02", publishedOn)
+	panicIfErr(err)
+	return &Article{
+		ID:          id,
+		Title:       title,
+		PublishedOn: t,
+		UpdatedOn:   t,
+		Status:      status,
+		inBlog:      true,
+	}
+}
+
+func mkTestArticles(articles ...*Article) *Articles {
+	res := &Articles{
+		idToArticle: map[string]*Article{},
+	}
+	for _, a := range articles {
+		res.idToArticle[a.ID] = a
+		res.articles = append(res.articles, a)
+		if a.IsBlog() {
+			res.blog = append(res.blog, a)
+		}
+	}
+	return res
+}
+
+func TestGenRSSFeed(t *testing.T) {
+	store := mkTestArticles(
+		mkTestArticle("1", "oldest", "2017-03-01", statusNormal),
+		mkTestArticle("2", "newest", "2019-01-15", statusNormal),
+		mkTestArticle("3", "hidden", "2019-02-01", statusHidden),
+		mkTestArticle("4", "not important", "2019-02-02", statusNotImportant),
+		mkTestArticle("5", "middle", "2018-06-10", statusNormal),
+	)
+	store.articles[1].Description = "newest description"
+
+	d, err := genRSSFeed(store)
+	assert.NoError(t, err)
+
+	var feed RSSFeed
+	err = xml.Unmarshal(d, &feed)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0", feed.Version)
+
+	items := feed.Channel.Items
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	assert.Equal(t, []string{"newest", "middle", "oldest"}, titles)
+
+	item := items[0]
+	assert.Equal(t, "https://blog.kowalczyk.info/article/newest-2/", item.Link)
+	assert.Equal(t, "newest description", item.Description)
+	_, err = time.Parse(time.RFC1123Z, item.PubDate)
+	assert.NoError(t, err)
+}
+
+func TestTagRSSFeed(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	defer func() {
+		allTags = nil
+	}()
+
+	a1 := mkTestArticle("1", "go one", "2018-01-01", statusNormal)
+	a1.Tags = []string{"go"}
+	a2 := mkTestArticle("2", "go and c++", "2019-01-01", statusNormal)
+	a2.Tags = []string{"go", "c++"}
+	a3 := mkTestArticle("3", "c++ only", "2019-02-01", statusNormal)
+	a3.Tags = []string{"c++"}
+	store := mkTestArticles(a1, a2, a3)
+	articles := store.getBlogNotHidden()
+	for tag, tagged := range groupArticlesByTag(articles) {
+		netlifyWriteArticlesArchiveForTag(store, tag, tagged)
+	}
+
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "tag", "go", "feed.xml"))
+	assert.NoError(t, err)
+	var feed RSSFeed
+	err = xml.Unmarshal(d, &feed)
+	assert.NoError(t, err)
+	var titles []string
+	for _, item := range feed.Channel.Items {
+		titles = append(titles, item.Title)
+	}
+	assert.Equal(t, []string{"go and c++", "go one"}, titles)
+	assert.Equal(t, "https://blog.kowalczyk.info/tag/go/", feed.Channel.Link)
+
+	d, err = ioutil.ReadFile(filepath.Join(flgOutDir, "tag", "go", "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), `<link rel="alternate" type="application/rss+xml" title="Articles tagged 'go'" href="/tag/go/feed.xml">`)
+}
@@ -0,0 +1,37 @@
+package main
+
+import "encoding/json"
+
+// SearchIndexEntry describes an article in search-index.json
+type SearchIndexEntry struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	URL   string   `json:"url"`
+	Tags  []string `json:"tags"`
+	// text of the article without formatting
+	Body string `json:"body"`
+}
+
+// genSearchIndex generates search-index.json used for searching articles
+// in the browser. Hidden articles are not included
+func genSearchIndex(articles []*Article) ([]byte, error) {
+	entries := []SearchIndexEntry{}
+	for _, a := range articles {
+		if a.IsHidden() {
+			continue
+		}
+		tags := a.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		e := SearchIndexEntry{
+			ID:    a.ID,
+			Title: a.Title,
+			URL:   a.URL(),
+			Tags:  tags,
+			Body:  pageText(a.page),
+		}
+		entries = append(entries, e)
+	}
+	return json.Marshal(entries)
+}
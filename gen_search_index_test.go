@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenSearchIndex(t *testing.T) {
+	a1 := mkTestArticle("1", "Go tips", "2019-03-04", statusNormal)
+	a1.Tags = []string{"go"}
+	toggle := mkTestBlock("tg", notionapi.BlockToggle, "More")
+	toggle.Content = []*notionapi.Block{
+		mkTestBlock("t3", notionapi.BlockText, "hidden  in toggle"),
+	}
+	code := mkTestBlock("c1", notionapi.BlockCode, "")
+	code.Code = "fmt.Println(\"hi\")"
+	text := mkTestBlock("t1", notionapi.BlockText, "")
+	text.InlineContent = []*notionapi.InlineBlock{
+		{Text: "Use "},
+		{Text: "gofmt", AttrFlags: notionapi.AttrCode},
+		{Text: " always."},
+	}
+	a1.page = mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "Formatting"),
+		text,
+		mkTestBlock("l1", notionapi.BlockBulletedList, "first item"),
+		toggle,
+		code,
+		mkTestBlock("p1", notionapi.BlockPage, "Sub page"),
+	)
+	a2 := mkTestArticle("2", "Hidden", "2018-05-01", statusHidden)
+
+	d, err := genSearchIndex([]*Article{a1, a2})
+	assert.NoError(t, err)
+	var entries []SearchIndexEntry
+	err = json.Unmarshal(d, &entries)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	e := entries[0]
+	assert.Equal(t, "1", e.ID)
+	assert.Equal(t, "Go tips", e.Title)
+	assert.Equal(t, "/article/go-tips-1/", e.URL)
+	assert.Equal(t, []string{"go"}, e.Tags)
+	assert.Equal(t, `Formatting Use gofmt always. first item More hidden in toggle fmt.Println("hi")`, e.Body)
+}
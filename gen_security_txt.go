@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// genSecurityTxt generates /.well-known/security.txt (RFC 9116) which tells
+// how to report security issues. contact is an url e.g. mailto:me@example.com
+func genSecurityTxt(contact string, expires time.Time, baseURL string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Contact: %s\n", contact)
+	fmt.Fprintf(&buf, "Expires: %s\n", expires.UTC().Format(time.RFC3339))
+	if baseURL != "" {
+		fmt.Fprintf(&buf, "Canonical: %s/.well-known/security.txt\n", strings.TrimSuffix(baseURL, "/"))
+	}
+	return buf.Bytes()
+}
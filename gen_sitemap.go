@@ -2,15 +2,15 @@ package main
 
 import (
 	"encoding/xml"
-	"path"
+	"strings"
 	"time"
 )
 
 // SiteMapURLSet represents <urlset>
 type SiteMapURLSet struct {
-	XMLName xml.Name `xml:"urlset"`
-	Ns      string   `xml:"xmlns,attr"`
-	URLS    []SiteMapURL
+	XMLName xml.Name     `xml:"urlset"`
+	Ns      string       `xml:"xmlns,attr"`
+	URLS    []SiteMapURL `xml:"url"`
 }
 
 func makeSiteMapURLSet() *SiteMapURLSet {
@@ -38,22 +38,31 @@ var staticURLS = []string{
 	"/documents.html",
 }
 
+// if host is empty, urls are relative
 func genSiteMap(store *Articles, host string) ([]byte, error) {
+	host = strings.TrimSuffix(host, "/")
 	articles := store.getNotHidden()
 	urlset := makeSiteMapURLSet()
 	var urls []SiteMapURL
 	for _, article := range articles {
-		pageURL := path.Join(host, article.URL())
+		if article.IsNoIndex() {
+			continue
+		}
+		pageURL := articleAbsURL(article, host)
+		lastModified := article.UpdatedOn
+		if lastModified.IsZero() {
+			lastModified = article.PublishedOn
+		}
 		uri := SiteMapURL{
 			URL:          pageURL,
-			LastModified: article.UpdatedOn.Format("2006-01-02"),
+			LastModified: lastModified.Format("2006-01-02"),
 		}
 		urls = append(urls, uri)
 	}
 
 	now := time.Now()
 	for _, staticURL := range staticURLS {
-		pageURL := path.Join(host, staticURL)
+		pageURL := host + staticURL
 		uri := SiteMapURL{
 			URL:          pageURL,
 			LastModified: now.Format("2006-01-02"),
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// returns url => last modified for articles in the sitemap
+func sitemapArticleURLs(t *testing.T, d []byte, host string) map[string]string {
+	var urlset SiteMapURLSet
+	err := xml.Unmarshal(d, &urlset)
+	assert.NoError(t, err)
+	res := map[string]string{}
+	for _, u := range urlset.URLS {
+		res[u.URL] = u.LastModified
+	}
+	for _, staticURL := range staticURLS {
+		uri := host + staticURL
+		assert.Contains(t, res, uri)
+		delete(res, uri)
+	}
+	return res
+}
+
+func TestGenSiteMap(t *testing.T) {
+	a1 := mkTestArticle("1", "first", "2018-01-01", statusNormal)
+	a1.UpdatedOn = time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)
+	a2 := mkTestArticle("2", "second", "2018-05-01", statusNormal)
+	a2.UpdatedOn = time.Time{}
+	a3 := mkTestArticle("3", "hidden", "2018-02-01", statusHidden)
+	store := mkTestArticles(a1, a2, a3)
+
+	d, err := genSiteMap(store, "https://blog.kowalczyk.info/")
+	assert.NoError(t, err)
+	urls := sitemapArticleURLs(t, d, "https://blog.kowalczyk.info")
+	exp := map[string]string{
+		"https://blog.kowalczyk.info/article/first-1/":  "2019-03-04",
+		"https://blog.kowalczyk.info/article/second-2/": "2018-05-01",
+	}
+	assert.Equal(t, exp, urls)
+
+	d, err = genSiteMap(store, "")
+	assert.NoError(t, err)
+	urls = sitemapArticleURLs(t, d, "")
+	exp = map[string]string{
+		"/article/first-1/":  "2019-03-04",
+		"/article/second-2/": "2018-05-01",
+	}
+	assert.Equal(t, exp, urls)
+}
+
+func TestNoIndex(t *testing.T) {
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Robots: NoIndex, nofollow"),
+		mkTestBlock("b2", notionapi.BlockText, "Article text"),
+	)
+	page.Root.Title = "Not indexed"
+	noIndex := notionPageToArticle(nil, page)
+	assert.Equal(t, "noindex, nofollow", noIndex.Robots)
+	assert.True(t, noIndex.IsNoIndex())
+	s := execTestArticleTemplate(t, noIndex)
+	assert.Contains(t, s, `<meta name="robots" content="noindex, nofollow">`)
+
+	indexed := mkTestArticle("2", "indexed", "2018-05-01", statusNormal)
+	assert.False(t, indexed.IsNoIndex())
+	s = execTestArticleTemplate(t, indexed)
+	assert.NotContains(t, s, `<meta name="robots"`)
+
+	store := mkTestArticles(noIndex, indexed)
+	d, err := genSiteMap(store, "")
+	assert.NoError(t, err)
+	urls := sitemapArticleURLs(t, d, "")
+	assert.Equal(t, 1, len(urls))
+	assert.Contains(t, urls, "/article/indexed-2/")
+
+	msg := recoverPanicMsg(func() {
+		setRobotsMust(indexed, "noindx")
+	})
+	assert.Contains(t, msg, "'noindx' in robots: metadata is not a valid directive")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// TagsListEntry describes a tag in tags.json
+type TagsListEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	URL   string `json:"url"`
+}
+
+// genTagsList generates tags.json with all tags, the number of articles
+// with a given tag and url of the tag page. Sorted by name.
+// Hidden articles are not counted
+func genTagsList(articles []*Article) ([]byte, error) {
+	tagCounts := map[string]int{}
+	for _, a := range articles {
+		if a.IsHidden() {
+			continue
+		}
+		for _, tag := range a.Tags {
+			tagCounts[tag]++
+		}
+	}
+	entries := []TagsListEntry{}
+	for tag, count := range tagCounts {
+		e := TagsListEntry{
+			Name:  tag,
+			Count: count,
+			URL:   tagURL(tag),
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return json.MarshalIndent(entries, "", "  ")
+}
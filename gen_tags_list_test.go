@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenTagsList(t *testing.T) {
+	mk := func(id string, status int, tags ...string) *Article {
+		a := mkTestArticle(id, "Article "+id, "2019-01-01", status)
+		a.Tags = tags
+		return a
+	}
+	articles := []*Article{
+		mk("1", statusNormal, "go", "notion"),
+		mk("2", statusNormal, "go", "c++"),
+		mk("3", statusNormal, "go"),
+		mk("4", statusNormal),
+		mk("5", statusHidden, "go", "secret"),
+		mk("6", statusDraft, "notion"),
+	}
+	d, err := genTagsList(articles)
+	assert.NoError(t, err)
+	var entries []TagsListEntry
+	err = json.Unmarshal(d, &entries)
+	assert.NoError(t, err)
+	exp := []TagsListEntry{
+		{Name: "c++", Count: 1, URL: "/tag/cplusplus"},
+		{Name: "go", Count: 3, URL: "/tag/go"},
+		{Name: "notion", Count: 1, URL: "/tag/notion"},
+	}
+	assert.Equal(t, exp, entries)
+
+	d, err = genTagsList(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(d))
+}
@@ -0,0 +1,39 @@
+package main
+
+import "encoding/json"
+
+const siteName = "Krzysztof Kowalczyk blog"
+
+type webManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// WebManifest describes site.webmanifest
+// https://developer.mozilla.org/en-US/docs/Web/Manifest
+type WebManifest struct {
+	Name      string            `json:"name"`
+	ShortName string            `json:"short_name"`
+	StartURL  string            `json:"start_url"`
+	Display   string            `json:"display"`
+	Icons     []webManifestIcon `json:"icons"`
+}
+
+// genWebManifest generates site.webmanifest for a site with a given name
+func genWebManifest(name string) ([]byte, error) {
+	m := WebManifest{
+		Name:      name,
+		ShortName: name,
+		StartURL:  "/",
+		Display:   "browser",
+		Icons: []webManifestIcon{
+			{
+				Src:   "/favicon.ico",
+				Sizes: "16x16 32x32",
+				Type:  "image/x-icon",
+			},
+		},
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenWebManifest(t *testing.T) {
+	defer setTestOutDir(t)()
+	data, err := genWebManifest(siteName)
+	assert.NoError(t, err)
+	netlifyWriteFile("/site.webmanifest", data)
+
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "site.webmanifest"))
+	assert.NoError(t, err)
+	var m WebManifest
+	err = json.Unmarshal(d, &m)
+	assert.NoError(t, err)
+	assert.Equal(t, "Krzysztof Kowalczyk blog", m.Name)
+	assert.Equal(t, "/", m.StartURL)
+	assert.Equal(t, "/favicon.ico", m.Icons[0].Src)
+}
+
+func TestFaviconLink(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	article := mkTestArticle("1", "My title", "2019-03-07", statusNormal)
+	website := mkTestArticle(notionWebsiteStartPage, "Website", "2019-01-01", statusNormal)
+	website.inBlog = false
+	store := mkTestArticles(article, website)
+
+	favicon := `<link rel="icon" href="/favicon.ico">`
+	s := execTestArticleTemplate(t, article)
+	head := s[:strings.Index(s, "</head>")]
+	assert.Contains(t, head, favicon)
+	assert.Contains(t, head, `<link rel="manifest" href="/site.webmanifest">`)
+
+	err := genIndex(store, nil)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "index.html"))
+	assert.NoError(t, err)
+	s = string(d)
+	head = s[:strings.Index(s, "</head>")]
+	assert.Contains(t, head, favicon)
+}
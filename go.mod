@@ -4,6 +4,7 @@ require (
 	github.com/alecthomas/chroma v0.6.3
 	github.com/alecthomas/template v0.0.0-20160405071501-a0175ee3bccc
 	github.com/chilts/sid v0.0.0-20180928232130-250d10e55bf4
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/gomarkdown/markdown v0.0.0-20181104084050-d1d0edeb5d85
 	github.com/kjk/betterguid v0.0.0-20170621091430-c442874ba63a
 	github.com/kjk/notionapi v0.0.0-20190418032306-16f5e01a3c80
@@ -16,6 +17,5 @@ require (
 	github.com/segmentio/ksuid v1.0.2
 	github.com/sony/sonyflake v0.0.0-20181109022403-6d5bd6181009
 	github.com/stretchr/testify v1.2.2
-	github.com/thomas11/atomgenerator v0.0.0-20140514140532-0b3b01da14a4
 	github.com/yosssi/gohtml v0.0.0-20190128141317-9b7db94d32d9
 )
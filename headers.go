@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// fingerprinted assets change name when content changes
+	cacheControlImmutable = "public, max-age=31536000, immutable"
+	// html must be checked with the server on every request
+	cacheControlRevalidate = "public, max-age=0, must-revalidate"
+)
+
+// headersRule is Cache-Control header for a path
+type headersRule struct {
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+}
+
+// headersFileName returns name of the file with headers for a given
+// -headers-format or "" if format is not supported
+func headersFileName(format string) string {
+	switch format {
+	case "netlify":
+		return "_headers"
+	case "cloudflare":
+		return "headers.json"
+	}
+	return ""
+}
+
+// buildHeadersRules returns Cache-Control headers for html files and
+// fingerprinted assets in dir
+func buildHeadersRules(dir string) ([]*headersRule, error) {
+	var res []*headersRule
+	add := func(uri string, cacheControl string) {
+		rule := &headersRule{
+			Path: uri,
+			Headers: map[string]string{
+				"Cache-Control": cacheControl,
+			},
+		}
+		res = append(res, rule)
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		uri := "/" + filepath.ToSlash(rel)
+		switch {
+		case fingerprintedRx.MatchString(uri):
+			add(uri, cacheControlImmutable)
+		case strings.HasSuffix(uri, "/index.html"):
+			// served as /foo/ and /foo/index.html
+			add(strings.TrimSuffix(uri, "index.html"), cacheControlRevalidate)
+			add(uri, cacheControlRevalidate)
+		case filepath.Ext(uri) == ".html":
+			add(uri, cacheControlRevalidate)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// genHeadersFile generates file with Cache-Control headers for files in dir.
+// For netlify it's _headers file, for cloudflare it's a json list of
+// path and headers for use in a worker
+func genHeadersFile(dir string, format string) ([]byte, error) {
+	rules, err := buildHeadersRules(dir)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "netlify":
+		var buf bytes.Buffer
+		for _, rule := range rules {
+			fmt.Fprintf(&buf, "%s\n  Cache-Control: %s\n", rule.Path, rule.Headers["Cache-Control"])
+		}
+		return buf.Bytes(), nil
+	case "cloudflare":
+		return json.MarshalIndent(rules, "", "  ")
+	}
+	return nil, fmt.Errorf("'%s' is not a valid headers format (netlify, cloudflare)", format)
+}
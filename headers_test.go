@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenHeadersFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_headers")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := []string{
+		"index.html",
+		"archives.html",
+		"article/foo-1/index.html",
+		"css/main.css",
+		"css/main.1a2b3c4d.css",
+		"js/copy.0a1b2c3d.js",
+		"img/photo.png",
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(name), 0644))
+	}
+
+	d, err := genHeadersFile(dir, "netlify")
+	assert.NoError(t, err)
+	exp := `/archives.html
+  Cache-Control: public, max-age=0, must-revalidate
+/article/foo-1/
+  Cache-Control: public, max-age=0, must-revalidate
+/article/foo-1/index.html
+  Cache-Control: public, max-age=0, must-revalidate
+/css/main.1a2b3c4d.css
+  Cache-Control: public, max-age=31536000, immutable
+/
+  Cache-Control: public, max-age=0, must-revalidate
+/index.html
+  Cache-Control: public, max-age=0, must-revalidate
+/js/copy.0a1b2c3d.js
+  Cache-Control: public, max-age=31536000, immutable
+`
+	assert.Equal(t, exp, string(d))
+
+	d, err = genHeadersFile(dir, "cloudflare")
+	assert.NoError(t, err)
+	var rules []*headersRule
+	err = json.Unmarshal(d, &rules)
+	assert.NoError(t, err)
+	cacheControl := map[string]string{}
+	for _, rule := range rules {
+		cacheControl[rule.Path] = rule.Headers["Cache-Control"]
+	}
+	assert.Equal(t, cacheControlImmutable, cacheControl["/css/main.1a2b3c4d.css"])
+	assert.Equal(t, cacheControlRevalidate, cacheControl["/article/foo-1/"])
+	// not fingerprinted files are not cached forever
+	assert.Equal(t, "", cacheControl["/css/main.css"])
+	assert.Equal(t, "", cacheControl["/img/photo.png"])
+
+	_, err = genHeadersFile(dir, "apache")
+	assert.Error(t, err)
+}
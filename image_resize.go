@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// widths of resized variants of images, used in srcset. Only variants
+// smaller than the original are generated
+var imageVariantWidths = []int{480, 960}
+
+// imageVariant is one of the sizes of an image
+type imageVariant struct {
+	URL   string
+	Width int
+}
+
+// resized images are stored next to images cached in cacheDir so they
+// are copied to the website by copyImages() and only generated once
+func resizedImagesDir() string {
+	return filepath.Join(cacheDir, "img", "resized")
+}
+
+// resizeImage scales down src to a given width, preserving aspect ratio.
+// Each destination pixel is an average of source pixels it covers
+func resizeImage(src image.Image, width int) image.Image {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := sb.Min.Y + y*sh/height
+		y1 := sb.Min.Y + (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := sb.Min.X + x*sw/width
+			x1 := sb.Min.X + (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(src.At(sx, sy)).(color.NRGBA)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+func decodeImageFile(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	return image.Decode(f)
+}
+
+func encodeImageFile(path string, img image.Image, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format == "jpeg" {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(f, img)
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// pages are converted to html concurrently and can use the same image
+var resizeImageMu sync.Mutex
+
+// genImageVariants creates resized versions of image at path (if they don't
+// already exist) and returns all sizes of the image, smallest first.
+// Returns nil for formats we can't resize
+func genImageVariants(path string) ([]imageVariant, error) {
+	resizeImageMu.Lock()
+	defer resizeImageMu.Unlock()
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []imageVariant
+	var img image.Image
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, width := range imageVariantWidths {
+		if width >= cfg.Width {
+			continue
+		}
+		variantName := fmt.Sprintf("%s-%dw%s", name, width, ext)
+		variantPath := filepath.Join(resizedImagesDir(), variantName)
+		if !fileExists(variantPath) {
+			if img == nil {
+				img, _, err = decodeImageFile(path)
+				if err != nil {
+					return nil, err
+				}
+				err = os.MkdirAll(resizedImagesDir(), 0755)
+				if err != nil {
+					return nil, err
+				}
+			}
+			err = encodeImageFile(variantPath, resizeImage(img, width), format)
+			if err != nil {
+				return nil, err
+			}
+			verbose("Resized %s to %s\n", path, variantPath)
+		}
+		v := imageVariant{
+			URL:   "/img/resized/" + variantName,
+			Width: width,
+		}
+		res = append(res, v)
+	}
+	orig := imageVariant{
+		URL:   "/img/" + filepath.Base(path),
+		Width: cfg.Width,
+	}
+	return append(res, orig), nil
+}
+
+// imageSrcset returns value of srcset attribute for image variants
+func imageSrcset(variants []imageVariant) string {
+	var parts []string
+	for _, v := range variants {
+		parts = append(parts, fmt.Sprintf("%s %dw", v.URL, v.Width))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// imageSizes returns value of sizes attribute for image variants. Images
+// take the whole width of the screen on small screens
+func imageSizes(variants []imageVariant) string {
+	maxWidth := variants[len(variants)-1].Width
+	return fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", maxWidth, maxWidth)
+}
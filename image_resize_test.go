@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderImageVariants(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1200, 600)))
+	assert.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir, prevLazy, prevResize := cacheDir, flgLazyImages, flgResizeImages
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir, flgLazyImages, flgResizeImages = prevCacheDir, prevLazy, prevResize
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	render := func() string {
+		block := mkTestBlock("i1", notionapi.BlockImage, "")
+		block.Source = srv.URL + "/photo.png"
+		page := mkTestPageWithBlocks(block)
+		r := NewHTMLRenderer(&notionapi.Client{}, page)
+		return string(r.Gen())
+	}
+	name := sha1OfLink(srv.URL + "/photo.png")
+
+	flgLazyImages, flgResizeImages = true, false
+	s := render()
+	assert.Contains(t, s, `loading="lazy"`)
+	assert.NotContains(t, s, "srcset")
+
+	flgLazyImages, flgResizeImages = false, true
+	s = render()
+	assert.NotContains(t, s, `loading="lazy"`)
+	srcset := "/img/resized/" + name + "-480w.png 480w, /img/resized/" + name + "-960w.png 960w, /img/" + name + ".png 1200w"
+	assert.Contains(t, s, `srcset="`+srcset+`"`)
+	assert.Contains(t, s, `sizes="(max-width: 1200px) 100vw, 1200px"`)
+	for _, width := range []int{480, 960} {
+		f, err := os.Open(filepath.Join(dir, "img", "resized", fmt.Sprintf("%s-%dw.png", name, width)))
+		assert.NoError(t, err)
+		cfg, err := png.DecodeConfig(f)
+		f.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, width, cfg.Width)
+		assert.Equal(t, width/2, cfg.Height)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// we remember hash of templates used to generate html files. If templates
+// changed since last build, incremental build becomes a full rebuild
+var templatesHashPath = filepath.Join(cacheDir, "templates.sha1.txt")
+
+// templatesHash returns sha1 of content of all templates we use and
+// of navigation bar links
+func templatesHash() string {
+	h := sha1.New()
+	for _, path := range templatePaths {
+		d, err := ioutil.ReadFile(path)
+		panicIfErr(err)
+		h.Write([]byte(path))
+		h.Write(d)
+	}
+	if d, err := ioutil.ReadFile(navPath); err == nil {
+		h.Write(d)
+	}
+	// with -fingerprint html has urls with hash of .css and .js files so
+	// when they change all html must be re-generated. Otherwise html files
+	// we skip would link to old files, which -prune deletes
+	if flgFingerprint {
+		h.Write([]byte("fingerprint"))
+		h.Write([]byte(assetsHash("www")))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// assetsHash returns sha1 of content of files in fingerprintDirs in dir
+func assetsHash(dir string) string {
+	h := sha1.New()
+	for _, subDir := range fingerprintDirs {
+		files, err := ioutil.ReadDir(filepath.Join(dir, subDir))
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			if fi.IsDir() {
+				continue
+			}
+			d, err := ioutil.ReadFile(filepath.Join(dir, subDir, fi.Name()))
+			panicIfErr(err)
+			h.Write([]byte(subDir + "/" + fi.Name()))
+			h.Write(d)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func readTemplatesHash() string {
+	d, err := ioutil.ReadFile(templatesHashPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(d))
+}
+
+func writeTemplatesHash(hash string) {
+	err := mkdirForFile(templatesHashPath)
+	panicIfErr(err)
+	err = ioutil.WriteFile(templatesHashPath, []byte(hash), 0644)
+	panicIfErr(err)
+}
+
+// we remember when the last build generated html files because modification
+// time of html files is not updated when their content didn't change
+var lastBuildTimePath = filepath.Join(cacheDir, "last_build_time.txt")
+
+// time of the last build, set by netlifyBuild for incremental builds
+var lastBuildTime time.Time
+
+func readLastBuildTime() time.Time {
+	d, err := ioutil.ReadFile(lastBuildTimePath)
+	if err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(d)))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func writeLastBuildTime(t time.Time) {
+	err := mkdirForFile(lastBuildTimePath)
+	panicIfErr(err)
+	err = ioutil.WriteFile(lastBuildTimePath, []byte(t.Format(time.RFC3339Nano)), 0644)
+	panicIfErr(err)
+}
+
+// isArticleChangedSince returns true if cached notion page of the article
+// or its last update time is newer than t
+func isArticleChangedSince(article *Article, t time.Time) bool {
+	if article.page == nil {
+		return true
+	}
+	cachedPath := filepath.Join(cacheDir, normalizeID(article.page.ID)+".json")
+	cachedStat, err := os.Stat(cachedPath)
+	if err != nil {
+		return true
+	}
+	if cachedStat.ModTime().After(t) {
+		return true
+	}
+	return article.UpdatedOn.After(t)
+}
+
+// isArticleHTMLUpToDate returns true if html file for the article is newer
+// than the article and its previous / next and related articles (their
+// titles and urls are part of the html)
+func isArticleHTMLUpToDate(article *Article, htmlPath string) bool {
+	htmlStat, err := os.Stat(htmlPath)
+	if err != nil {
+		return false
+	}
+	// html is not re-written if it didn't change so it can be older
+	// than the last build that generated it
+	htmlTime := htmlStat.ModTime()
+	if lastBuildTime.After(htmlTime) {
+		htmlTime = lastBuildTime
+	}
+	if isArticleChangedSince(article, htmlTime) {
+		return false
+	}
+	linked := append([]*Article{article.PrevArticle, article.NextArticle}, article.RelatedArticles...)
+	for _, a := range linked {
+		if a != nil && isArticleChangedSince(a, htmlTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// netlifyWriteArticle writes html for the article. If incremental is true
+// and html file is up-to-date, we don't re-generate it.
+// Returns true if the file was written
+func netlifyWriteArticle(article *Article, path string, incremental bool) bool {
+	if incremental && isArticleHTMLUpToDate(article, netlifyPath(path)) {
+		lg("skipping %s (%s), not changed\n", path, article.Title)
+		markGenerated(netlifyPath(path))
+		return false
+	}
+	if incremental {
+		lg("regenerating %s (%s)\n", path, article.Title)
+	}
+	model := makeArticleModel(article)
+	netlifyExecTemplate(path, articleTemplate(article), model)
+	netlifyCopyArticleCSS(article)
+	return true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalArticleBuild(t *testing.T) {
+	loadTemplates()
+	dir, err := ioutil.TempDir("", "blog_incremental")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	err = os.Chdir(dir)
+	assert.NoError(t, err)
+	defer os.Chdir(cwd)
+	defer setTestOutDir(t)()
+
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+	article.page = mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "text"))
+	cachedPath := filepath.Join(cacheDir, normalizeID(article.page.ID)+".json")
+	err = mkdirForFile(cachedPath)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(cachedPath, []byte("{}"), 0644)
+	assert.NoError(t, err)
+	now := time.Now()
+	err = os.Chtimes(cachedPath, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	assert.NoError(t, err)
+
+	path := netlifyArticlePath(article)
+	htmlPath := netlifyPath(path)
+	assert.True(t, netlifyWriteArticle(article, path, true))
+
+	htmlTime := now.Add(-time.Hour).Truncate(time.Second)
+	err = os.Chtimes(htmlPath, htmlTime, htmlTime)
+	assert.NoError(t, err)
+	assert.False(t, netlifyWriteArticle(article, path, true))
+	st, err := os.Stat(htmlPath)
+	assert.NoError(t, err)
+	assert.True(t, st.ModTime().Equal(htmlTime))
+
+	// not incremental always re-generates
+	assert.True(t, netlifyWriteArticle(article, path, false))
+
+	// newer cached page re-generates
+	err = os.Chtimes(htmlPath, htmlTime, htmlTime)
+	assert.NoError(t, err)
+	err = os.Chtimes(cachedPath, now, now)
+	assert.NoError(t, err)
+	assert.True(t, netlifyWriteArticle(article, path, true))
+	// html is the same so the file is not touched
+	st, err = os.Stat(htmlPath)
+	assert.NoError(t, err)
+	assert.True(t, st.ModTime().Equal(htmlTime))
+
+	// cached page re-downloaded before the last build, which generated
+	// the same html, doesn't re-generate
+	err = os.Chtimes(cachedPath, htmlTime.Add(time.Minute), htmlTime.Add(time.Minute))
+	assert.NoError(t, err)
+	writeLastBuildTime(htmlTime.Add(2 * time.Minute))
+	lastBuildTime = readLastBuildTime()
+	assert.True(t, lastBuildTime.Equal(htmlTime.Add(2*time.Minute)))
+	assert.False(t, netlifyWriteArticle(article, path, true))
+	lastBuildTime = time.Time{}
+	assert.True(t, netlifyWriteArticle(article, path, true))
+
+	// a new next article re-generates
+	err = os.Chtimes(cachedPath, htmlTime.Add(-time.Hour), htmlTime.Add(-time.Hour))
+	assert.NoError(t, err)
+	err = os.Chtimes(htmlPath, htmlTime, htmlTime)
+	assert.NoError(t, err)
+	assert.False(t, netlifyWriteArticle(article, path, true))
+	article.NextArticle = mkTestArticle("2", "Newer", "2019-01-02", statusNormal)
+	assert.True(t, netlifyWriteArticle(article, path, true))
+
+	// a renamed related article re-generates
+	article.NextArticle = nil
+	related := mkTestArticle("3", "Related", "2018-01-01", statusNormal)
+	related.page = mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "text"))
+	related.page.ID = mkTestPageID(3)
+	relatedCachedPath := filepath.Join(cacheDir, normalizeID(related.page.ID)+".json")
+	err = ioutil.WriteFile(relatedCachedPath, []byte("{}"), 0644)
+	assert.NoError(t, err)
+	err = os.Chtimes(relatedCachedPath, htmlTime.Add(-time.Hour), htmlTime.Add(-time.Hour))
+	assert.NoError(t, err)
+	article.RelatedArticles = []*Article{related}
+	err = os.Chtimes(htmlPath, htmlTime, htmlTime)
+	assert.NoError(t, err)
+	assert.False(t, netlifyWriteArticle(article, path, true))
+	err = os.Chtimes(relatedCachedPath, now, now)
+	assert.NoError(t, err)
+	assert.True(t, netlifyWriteArticle(article, path, true))
+}
+
+func TestTemplatesHash(t *testing.T) {
+	loadTemplates()
+	h := templatesHash()
+	assert.Equal(t, 40, len(h))
+	assert.Equal(t, h, templatesHash())
+
+	// changing .css or .js files changes the hash if they are fingerprinted
+	defer func() { flgFingerprint = false }()
+	flgFingerprint = true
+	assert.NotEqual(t, h, templatesHash())
+
+	dir, err := ioutil.TempDir("", "blog_assets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "css", "main.css")
+	err = mkdirForFile(path)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(path, []byte("body {}"), 0644)
+	assert.NoError(t, err)
+	ah := assetsHash(dir)
+	assert.Equal(t, ah, assetsHash(dir))
+	err = ioutil.WriteFile(path, []byte("body { color: red }"), 0644)
+	assert.NoError(t, err)
+	assert.NotEqual(t, ah, assetsHash(dir))
+}
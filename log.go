@@ -1,21 +1,113 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range logLevelNames {
+		if s == name {
+			return logLevel(i), nil
+		}
+	}
+	return levelInfo, fmt.Errorf("'%s' is not a valid log level, must be one of: %s", s, strings.Join(logLevelNames, ", "))
+}
+
+// logger is where lg(), verbose(), logWarn() and logError() send messages
+type logger interface {
+	Log(level logLevel, msg string)
+}
+
+// textLogger writes all messages as is to log file and messages
+// of at least consoleLogLevel to stdout
+type textLogger struct{}
+
+func (textLogger) Log(level logLevel, msg string) {
+	if logFile != nil {
+		fmt.Fprint(logFile, msg)
+	}
+	if level >= consoleLogLevel {
+		fmt.Print(msg)
+	}
+}
+
+// jsonLogEntry is a single line written by jsonLogger
+type jsonLogEntry struct {
+	Level  string `json:"level"`
+	Msg    string `json:"msg"`
+	PageID string `json:"page_id,omitempty"`
+	Ts     string `json:"ts"`
+}
+
+var logPageIDRx = regexp.MustCompile(`[0-9a-f]{32}`)
+
+// jsonLogger writes messages as line-delimited json, enabled with -log-json
+type jsonLogger struct{}
+
+func (jsonLogger) Log(level logLevel, msg string) {
+	e := jsonLogEntry{
+		Level:  level.String(),
+		Msg:    strings.TrimSpace(msg),
+		PageID: logPageIDRx.FindString(msg),
+		Ts:     time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	d, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	d = append(d, '\n')
+	if logFile != nil {
+		logFile.Write(d)
+	}
+	if level >= consoleLogLevel {
+		os.Stdout.Write(d)
+	}
+}
+
 var (
+	// logMu serializes writes to logFile and stdout so that messages
+	// logged from concurrent page downloads don't get interleaved
+	logMu   sync.Mutex
 	logFile *os.File
+
+	currLogger logger = textLogger{}
+	// log file gets all messages, stdout only those of at least this level
+	consoleLogLevel = levelInfo
 )
 
 func openLog() {
+	logMu.Lock()
+	defer logMu.Unlock()
 	var err error
 	logFile, err = os.Create("log.txt")
 	must(err)
 }
 
 func closeLog() {
+	logMu.Lock()
+	defer logMu.Unlock()
 	if logFile == nil {
 		return
 	}
@@ -23,30 +115,39 @@ func closeLog() {
 	logFile = nil
 }
 
-// TODO: should take additional format and args for optional message
-func logError(err error) {
-	if err != nil {
+// logMsg sends msg to currLogger. It's safe to call from multiple goroutines
+func logMsg(level logLevel, msg string) {
+	logMu.Lock()
+	currLogger.Log(level, msg)
+	logMu.Unlock()
+}
+
+// logError logs err. Optional args are a format string and arguments
+// describing the context in which the error happened
+func logError(err error, args ...interface{}) {
+	if err == nil {
+		return
+	}
+	s := FmtArgs(args...)
+	if s == "" {
+		logMsg(levelError, fmt.Sprintf("%s\n", err))
 		return
 	}
-	lg("%s", err.Error())
+	logMsg(levelError, fmt.Sprintf("%s: %s\n", s, err))
 }
 
 func lg(format string, args ...interface{}) {
 	s := fmt.Sprintf(format, args...)
-	if logFile != nil {
-		fmt.Fprint(logFile, s)
-	}
-	fmt.Print(s)
+	logMsg(levelInfo, s)
 }
 
-// TODO: have just one
-func logVerbose(format string, args ...interface{}) {
-	verbose(format, args...)
+func logWarn(format string, args ...interface{}) {
+	s := fmt.Sprintf(format, args...)
+	logMsg(levelWarn, s)
 }
 
+// verbose logs at debug level, shown on stdout with -verbose
 func verbose(format string, args ...interface{}) {
 	s := fmt.Sprintf(format, args...)
-	if logFile != nil {
-		fmt.Fprint(logFile, s)
-	}
+	logMsg(levelDebug, s)
 }
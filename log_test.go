@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogError(t *testing.T) {
+	f, err := ioutil.TempFile("", "log_test")
+	assert.NoError(t, err)
+	path := f.Name()
+	defer os.Remove(path)
+
+	prevLogFile := logFile
+	logFile = f
+	logError(nil, "must not be logged")
+	logError(errors.New("connection reset"), "failed to load page %s", "1234")
+	logError(errors.New("no annotation"))
+	logFile = prevLogFile
+	f.Close()
+
+	d, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	s := string(d)
+	assert.NotContains(t, s, "must not be logged")
+	assert.Contains(t, s, "failed to load page 1234: connection reset\n")
+	assert.Contains(t, s, "no annotation\n")
+}
+
+func TestJSONLogger(t *testing.T) {
+	f, err := ioutil.TempFile("", "log_test")
+	assert.NoError(t, err)
+	path := f.Name()
+	defer os.Remove(path)
+
+	prevLogFile, prevLogger := logFile, currLogger
+	logFile = f
+	currLogger = jsonLogger{}
+	lg("Page %s: downloaded\n", "ea07db1b9bff415ab180b0525f3898f6")
+	verbose("details\n")
+	logError(errors.New("connection reset"), "failed to load page")
+	logFile, currLogger = prevLogFile, prevLogger
+	f.Close()
+
+	d, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(d)), "\n")
+	assert.Equal(t, 3, len(lines))
+	var entries []jsonLogEntry
+	for _, line := range lines {
+		var e jsonLogEntry
+		err = json.Unmarshal([]byte(line), &e)
+		assert.NoError(t, err)
+		_, err = time.Parse(time.RFC3339Nano, e.Ts)
+		assert.NoError(t, err)
+		entries = append(entries, e)
+	}
+	assert.Equal(t, "info", entries[0].Level)
+	assert.Equal(t, "Page ea07db1b9bff415ab180b0525f3898f6: downloaded", entries[0].Msg)
+	assert.Equal(t, "ea07db1b9bff415ab180b0525f3898f6", entries[0].PageID)
+	assert.Equal(t, "debug", entries[1].Level)
+	assert.Equal(t, "", entries[1].PageID)
+	assert.Equal(t, "error", entries[2].Level)
+	assert.Equal(t, "failed to load page: connection reset", entries[2].Msg)
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp logLevel
+	}{
+		{"debug", levelDebug},
+		{"info", levelInfo},
+		{" WARN", levelWarn},
+		{"error", levelError},
+	}
+	for _, test := range tests {
+		got, err := parseLogLevel(test.s)
+		assert.NoError(t, err)
+		assert.Equal(t, test.exp, got)
+	}
+	_, err := parseLogLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestConsoleLogLevel(t *testing.T) {
+	f, err := ioutil.TempFile("", "log_test")
+	assert.NoError(t, err)
+	path := f.Name()
+	defer os.Remove(path)
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+
+	prevLogFile, prevStdout, prevLevel := logFile, os.Stdout, consoleLogLevel
+	logFile, os.Stdout, consoleLogLevel = f, w, levelWarn
+	verbose("debug msg\n")
+	lg("info msg\n")
+	logWarn("warn msg\n")
+	logError(errors.New("error msg"))
+	logFile, os.Stdout, consoleLogLevel = prevLogFile, prevStdout, prevLevel
+	w.Close()
+	f.Close()
+
+	console, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "warn msg\nerror msg\n", string(console))
+
+	// log file gets all messages regardless of level
+	d, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "debug msg\ninfo msg\nwarn msg\nerror msg\n", string(d))
+}
+
+func TestConcurrentLogging(t *testing.T) {
+	f, err := ioutil.TempFile("", "log_test")
+	assert.NoError(t, err)
+	path := f.Name()
+	defer os.Remove(path)
+
+	prevLogFile, prevLevel := logFile, consoleLogLevel
+	logFile, consoleLogLevel = f, levelError
+	nGoroutines, nMessages := 16, 100
+	var wg sync.WaitGroup
+	for i := 0; i < nGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < nMessages; j++ {
+				if j%2 == 0 {
+					lg("goroutine %d message %d\n", i, j)
+				} else {
+					verbose("goroutine %d message %d\n", i, j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	logFile, consoleLogLevel = prevLogFile, prevLevel
+	f.Close()
+
+	d, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(d)), "\n")
+	assert.Equal(t, nGoroutines*nMessages, len(lines))
+	seen := map[string]bool{}
+	for _, line := range lines {
+		var i, j int
+		_, err = fmt.Sscanf(line, "goroutine %d message %d", &i, &j)
+		assert.NoError(t, err, "garbled line: %q", line)
+		seen[line] = true
+	}
+	assert.Equal(t, nGoroutines*nMessages, len(seen))
+}
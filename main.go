@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/kjk/notionapi"
@@ -18,26 +19,209 @@ var (
 
 	flgRedownloadNotion bool
 	flgRedownloadPage   string
+	flgPurge            string
+	flgCacheTTL         time.Duration
+	flgMinify           bool
+	flgLogJSON          bool
+	flgLogLevel         string
 	flgDeploy           bool
 	flgPreview          bool
 	flgPreviewOnDemand  bool
 	flgVerbose          bool
+	flgBaseURL          string
+	flgConcurrency      int
+	flgHighlightStyle   string
+	flgTocMinHeaders    int
+	flgWordsPerMinute   int
+	flgPageSize         int
+	flgIncremental      bool
+	flgIncludeDrafts    bool
+	flgIncludeFuture    bool
+	flgOutDir           string
+	flgDryRun           bool
+	flgRequiredMeta     string
+	flgStrictMeta       bool
+	flgStrictAlt        bool
+	flgDateFormat       string
+	flgCheckLinks       bool
+	flgRoots            string
+	flgRobotsDisallow   stringsFlag
+	flgKeepGoing        bool
+	flgCollection       string
+	flgFetchBookmarks   bool
+	flgServe            bool
+	flgPort             int
+	flgWatch            bool
+	flgAuthor           string
+	flgPurgeCSS         bool
+	flgEmitMarkdown     bool
+	flgLazyImages       bool
+	flgResizeImages     bool
+	flgPrecompress      bool
+	flgFingerprint      bool
+	flgVersion          bool
+	flgCollectionBase   stringsFlag
+	flgOnly             string
+	flgConfig           string
+	flgToken            string
+	flgHeadersFormat    string
+	flgRedirectsFile    string
+	flgClean            bool
+	flgPrune            bool
+	flgHumansContact    string
+	flgSecurityContact  string
+	flgSecurityExpires  time.Duration
+	flgDownloadAttempts int
 )
 
+// stringsFlag is a flag that can be given multiple times
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
 func parseCmdLineFlags() {
-	flag.BoolVar(&flgVerbose, "verbose", false, "if true, verbose logging")
+	flag.BoolVar(&flgVerbose, "verbose", false, "if true, verbose logging (same as -log-level debug)")
+	flag.BoolVar(&flgVerbose, "v", false, "same as -verbose")
+	flag.StringVar(&flgLogLevel, "log-level", "info", "minimum level (debug, info, warn, error) of messages shown on stdout. log.txt has all messages")
 	flag.BoolVar(&flgDeploy, "deploy", false, "if true, build for deployment")
 	flag.BoolVar(&flgPreview, "preview", false, "if true, runs caddy and opens a browser for preview")
 	flag.BoolVar(&flgPreviewOnDemand, "preview-on-demand", false, "if true runs the browser for local preview")
 	flag.BoolVar(&flgRedownloadNotion, "redownload-notion", false, "if true, re-downloads content from notion")
 	flag.StringVar(&flgRedownloadPage, "redownload-page", "", "if given, redownloads content for one page")
+	flag.StringVar(&flgPurge, "purge", "", "if given, removes cached data for page with this id and exits")
+	flag.DurationVar(&flgCacheTTL, "cache-ttl", 0, "if given (e.g. 24h), cached notion pages older than that are re-downloaded. 0 means cache forever")
+	flag.IntVar(&flgConcurrency, "concurrency", 4, "number of notion pages to download at the same time")
+	flag.IntVar(&flgDownloadAttempts, "download-attempts", 3, "how many times to try downloading a notion page before giving up")
+	flag.StringVar(&flgHighlightStyle, "highlight-style", "monokailight", "chroma style used for highlighting code")
+	flag.IntVar(&flgTocMinHeaders, "toc-min-headers", 3, "show table of contents for pages with more than this many headers")
+	flag.IntVar(&flgWordsPerMinute, "words-per-minute", 200, "reading speed used to estimate reading time of articles")
+	flag.IntVar(&flgPageSize, "page-size", 20, "number of articles on index page. Older articles are on /page/2/, /page/3/ etc. 0 means all articles on one page")
+	flag.BoolVar(&flgIncremental, "incremental", false, "only re-generate html for articles that changed since last build")
+	flag.BoolVar(&flgIncludeDrafts, "include-drafts", false, "if true, generates html for articles with draft status")
+	flag.BoolVar(&flgIncludeFuture, "include-future", false, "if true, lists articles with date in the future in index, feeds and sitemap")
+	flag.StringVar(&flgOutDir, "out", "netlify_static", "directory where generated files are written")
+	flag.BoolVar(&flgDryRun, "dry-run", false, "if true, only logs which files would be generated without writing them")
+	flag.StringVar(&flgRequiredMeta, "required-meta", "", "comma-separated list of metadata keys (e.g. 'date,tags') every page must have")
+	flag.BoolVar(&flgStrictMeta, "strict-meta", false, "if true, missing required metadata is an error instead of a warning")
+	flag.BoolVar(&flgStrictAlt, "strict-alt", false, "if true, image without alt text (caption or file name) is an error instead of a warning")
+	flag.BoolVar(&flgMinify, "minify", false, "if true, minifies generated html files")
+	flag.BoolVar(&flgLogJSON, "log-json", false, "if true, logs as line-delimited json")
+	flag.StringVar(&flgDateFormat, "date-format", defaultDateFormat, "Go time layout used to display dates of articles")
+	flag.BoolVar(&flgCheckLinks, "check-links", false, "if true, reports links to articles and tags that don't exist in generated files")
+	flag.StringVar(&flgRoots, "roots", "", "comma-separated list of ids or urls of notion pages to start crawling from. The first one is used for index.html")
+	flag.Var(&flgRobotsDisallow, "robots-disallow", "path to disallow in robots.txt, can be given multiple times. If not given, /tag/, /notes/ and /page/ are disallowed")
+	flag.BoolVar(&flgKeepGoing, "keep-going", false, "if true, pages that fail to download or convert are skipped and reported at the end instead of stopping the build")
+	flag.StringVar(&flgCollection, "collection", "", "id or url of notion page with a collection (database) whose rows are blog posts. Date, Tags, Status etc. are read from collection's properties")
+	flag.BoolVar(&flgFetchBookmarks, "fetch-bookmarks", false, "if true, fetches title, description and image of bookmarked pages that notion didn't provide")
+	flag.BoolVar(&flgServe, "serve", false, "if true, serves generated website from -out directory after building it")
+	flag.IntVar(&flgPort, "port", 8080, "port used by -serve")
+	flag.BoolVar(&flgWatch, "watch", false, "if true, after building watches notion cache directory and re-generates html of pages whose cache changed")
+	flag.StringVar(&flgAuthor, "author", defaultAuthor, "author of articles that don't have 'author:' metadata")
+	flag.BoolVar(&flgPurgeCSS, "purge-css", false, "if true, removes rules not used by generated html files from main.css")
+	flag.BoolVar(&flgEmitMarkdown, "emit-markdown", false, "if true, also writes each article as markdown with metadata in yaml frontmatter to article/<slug>/index.md")
+	flag.BoolVar(&flgLazyImages, "lazy-images", true, "if true, adds loading=\"lazy\" to images in articles")
+	flag.BoolVar(&flgResizeImages, "resize-images", false, "if true, generates smaller variants of images in articles and lists them in srcset")
+	flag.BoolVar(&flgPrecompress, "precompress", false, "if true, writes .gz versions of html, css, js and json files in the output directory")
+	flag.BoolVar(&flgFingerprint, "fingerprint", false, "if true, adds a hash of content to names of .css and .js files and updates links to them in html files")
+	flag.BoolVar(&flgVersion, "version", false, "if true, prints version and exits")
+	flag.Var(&flgCollectionBase, "collection-base", "collection=url, absolute url of the website for pages in a collection (e.g. \"Go Cookbook=https://go.kowalczyk.info\"), can be given multiple times")
+	flag.StringVar(&flgOnly, "only", "", "id or url of a notion page. If given, only re-generates html of that page and the index page")
+	flag.StringVar(&flgConfig, "config", configPath, "json file with default values of flags, keyed by flag name (e.g. {\"out\": \"netlify_static\"}). Flags given on command line over-write them")
+	flag.StringVar(&flgToken, "token", "", "notion token (value of token_v2 cookie), needed to download pages. If not given, NOTION_TOKEN environment variable is used")
+	flag.StringVar(&flgHeadersFormat, "headers-format", "", "if given (netlify or cloudflare), writes a file with Cache-Control headers for html files and fingerprinted assets (_headers for netlify, headers.json for cloudflare)")
+	flag.StringVar(&flgRedirectsFile, "redirects-file", "", "if given, file with permanent redirects added to _redirects, one '<from> <to>' per line")
+	flag.BoolVar(&flgClean, "clean", false, "if true, removes all files in -out directory before generating. It must be a directory generated by previous build")
+	flag.BoolVar(&flgPrune, "prune", false, "if true, removes files in -out directory that were not generated by this build e.g. pages of deleted articles")
+	flag.StringVar(&flgHumansContact, "humans-contact", "", "if given, contact of the author (e.g. email) written to humans.txt together with -author. humans.txt is not written without it")
+	flag.StringVar(&flgSecurityContact, "security-contact", "", "if given, url for reporting security issues (e.g. mailto:me@example.com) written to /.well-known/security.txt. security.txt is not written without it")
+	flag.DurationVar(&flgSecurityExpires, "security-expires", 365*24*time.Hour, "how long after the build security.txt is valid")
+	flag.StringVar(&flgBaseURL, "base-url", "", "if given, absolute url of the website (e.g. https://blog.kowalczyk.info) used in sitemap.xml")
 	flag.Parse()
+
+	// -config must exist if given explicitly
+	config, err := loadConfig(flgConfig, flgConfig != configPath)
+	if err == nil {
+		err = applyConfig(flag.CommandLine, config)
+	}
+	if err == nil {
+		err = checkOutDir(flgOutDir)
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	requiredMetaKeys = parseRequiredMetaKeys(flgRequiredMeta)
+	if flgLogJSON {
+		currLogger = jsonLogger{}
+	}
+	consoleLogLevel, err = parseLogLevel(flgLogLevel)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	if flgVerbose {
+		consoleLogLevel = levelDebug
+	}
+
+	if flgRoots != "" {
+		rootPageIDs, err = parseRootPageIDs(flgRoots)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if flgCollection != "" {
+		collectionPageID, err = parseNotionPageID(flgCollection)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if flgHeadersFormat != "" && headersFileName(flgHeadersFormat) == "" {
+		fmt.Printf("'%s' is not a valid -headers-format (netlify, cloudflare)\n", flgHeadersFormat)
+		os.Exit(1)
+	}
+
+	err = setHighlightStyle(flgHighlightStyle)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	collectionBaseURLs, err = parseCollectionBaseURLs(flgCollectionBase)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	if flgRedirectsFile != "" {
+		manualRedirects, err = loadManualRedirects(flgRedirectsFile)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
 }
 
 func rebuildAll(c *notionapi.Client) *Articles {
 	regenMd()
 	loadTemplates()
 	articles := loadArticles(c)
+	err := checkDuplicateIDs(articles.articles)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
 	readRedirects(articles)
 	netlifyBuild(articles)
 	return articles
@@ -83,14 +267,60 @@ func preview() {
 
 func main() {
 	parseCmdLineFlags()
-	os.MkdirAll("netlify_static", 0755)
+
+	if flgVersion {
+		fmt.Printf("%s\n", version)
+		return
+	}
+
+	if flgPurge != "" {
+		err := purgeCachedPage(flgPurge)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := preflightCheck(); err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	if flgClean && !flgDryRun {
+		if err := cleanOutDir(flgOutDir); err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if !flgDryRun {
+		err := os.MkdirAll(flgOutDir, 0755)
+		panicIfErr(err)
+	}
 
 	client := &notionapi.Client{}
+	// it's only an error if we need to download a page, which we
+	// don't know until we check the cache
+	client.AuthToken, _ = loadNotionToken()
+
+	if flgOnly != "" {
+		pageID, err := parseNotionPageID(flgOnly)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		rebuildOnly(client, pageID)
+		return
+	}
 
 	// make sure this happens first so that building for deployment is not
 	// disrupted by the temporary testing code we might have below
 	if flgDeploy {
 		rebuildAll(client)
+		if reportPageFailures() > 0 {
+			os.Exit(1)
+		}
 		return
 	}
 
@@ -100,6 +330,7 @@ func main() {
 	}
 
 	articles := rebuildAll(client)
+	nFailed := reportPageFailures()
 
 	if flgPreview {
 		preview()
@@ -110,4 +341,22 @@ func main() {
 		startPreviewOnDemand(articles)
 		return
 	}
+
+	if flgWatch && !flgDryRun {
+		// runs until the process exits
+		go watchCache(client, articles, 500*time.Millisecond, nil)
+		if !flgServe {
+			waitForSignal()
+			return
+		}
+	}
+
+	if flgServe && !flgDryRun {
+		startServe(flgOutDir, flgPort)
+		return
+	}
+
+	if nFailed > 0 {
+		os.Exit(1)
+	}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 
 	"github.com/alecthomas/chroma"
@@ -22,10 +24,27 @@ var (
 func init() {
 	htmlFormatter = html.New(html.WithClasses(), html.TabWidth(2))
 	panicIf(htmlFormatter == nil, "couldn't create html formatter")
-	styleName := "monokailight"
-	highlightStyle = styles.Get(styleName)
-	panicIf(highlightStyle == nil, "didn't find style '%s'", styleName)
+	err := setHighlightStyle("monokailight")
+	panicIfErr(err)
+}
+
+func setHighlightStyle(styleName string) error {
+	style, ok := styles.Registry[styleName]
+	if !ok {
+		return fmt.Errorf("'%s' is not a valid highlight style. Valid styles: %v", styleName, styles.Names())
+	}
+	highlightStyle = style
+	return nil
+}
 
+// genHighlightCSS returns css for classes generated by htmlHighlight
+func genHighlightCSS() ([]byte, error) {
+	var buf bytes.Buffer
+	err := htmlFormatter.WriteCSS(&buf, highlightStyle)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // based on https://github.com/alecthomas/chroma/blob/master/quick/quick.go
@@ -44,6 +63,10 @@ func htmlHighlight(w io.Writer, source, lang, defaultLang string) error {
 
 	it, err := l.Tokenise(nil, source)
 	if err != nil {
+		// show the code as-is rather than not at all
+		io.WriteString(w, `<pre class="chroma"><code>`)
+		mdhtml.EscapeHTML(w, []byte(source))
+		io.WriteString(w, "</code></pre>\n")
 		return err
 	}
 	return htmlFormatter.Format(w, highlightStyle, it)
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLHighlight(t *testing.T) {
+	var buf bytes.Buffer
+	err := htmlHighlight(&buf, "func main() {\n}\n", "go", "")
+	assert.NoError(t, err)
+	s := buf.String()
+	assert.Contains(t, s, `<pre class="chroma">`)
+	// "func" is a keyword
+	assert.Contains(t, s, `<span class="kd">func</span>`)
+
+	buf.Reset()
+	src := "some <plain> text\n"
+	err = htmlHighlight(&buf, src, "not-a-language", "")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "some &lt;plain&gt; text")
+}
+
+func TestGenHighlightCSS(t *testing.T) {
+	defer setHighlightStyle("monokailight")
+
+	err := setHighlightStyle("no-such-style")
+	assert.Error(t, err)
+
+	err = setHighlightStyle("github")
+	assert.NoError(t, err)
+	d, err := genHighlightCSS()
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), ".chroma .kd")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+)
+
+// navItem is a link in the navigation bar at the top of pages
+type navItem struct {
+	Label string `json:"label"`
+	Href  string `json:"href"`
+}
+
+var (
+	// file with a json list of navItem. If it doesn't exist,
+	// we use defaultNavItems
+	navPath = "nav.json"
+
+	defaultNavItems = []navItem{
+		{Label: "Software", Href: "/software/"},
+		{Label: "About Me", Href: "/resume.html"},
+	}
+
+	navItems = defaultNavItems
+)
+
+// loadNavItems reads navigation items from navPath
+func loadNavItems() ([]navItem, error) {
+	d, err := ioutil.ReadFile(navPath)
+	if os.IsNotExist(err) {
+		return defaultNavItems, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []navItem
+	err = json.Unmarshal(d, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", navPath, err)
+	}
+	return res, nil
+}
+
+// renderNav returns html of the navigation bar with navItems
+func renderNav() template.HTML {
+	var buf bytes.Buffer
+	buf.WriteString("<ul id=\"nav\">\n")
+	for i, item := range navItems {
+		if i > 0 {
+			buf.WriteString("    <li>\n      <span style=\"color:#aaa\">&bull;</span>\n    </li>\n")
+		}
+		fmt.Fprintf(&buf, "    <li>\n      <a href=\"%s\">%s</a>\n    </li>\n", html.EscapeString(item.Href), html.EscapeString(item.Label))
+	}
+	buf.WriteString("  </ul>")
+	return template.HTML(buf.String())
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/kjk/notionapi"
+)
+
+// collectionPageID is a notion page with a collection (database) whose
+// rows are blog posts, set with -collection
+var collectionPageID string
+
+// collectionRow is a row of notion collection. Each row is a page
+type collectionRow struct {
+	pageID string
+	// property values keyed by metadata key e.g. "Published On" => "publishedon"
+	props map[string]string
+}
+
+// collectionPropKey converts name of a collection property to metadata key
+func collectionPropKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+// collectionPropValue returns value of a collection property as text. Dates
+// are in "2006-01-02" format, values of multi-select are comma-separated
+func collectionPropValue(v interface{}) string {
+	inline, err := notionapi.ParseInlineBlocks(v)
+	if err != nil {
+		return ""
+	}
+	for _, b := range inline {
+		if b.Date != nil {
+			return b.Date.StartDate
+		}
+	}
+	return strings.TrimSpace(inlinesToText(inline))
+}
+
+// findCollectionRows returns rows of all collections in the page
+func findCollectionRows(page *notionapi.Page) []*collectionRow {
+	var res []*collectionRow
+	seen := map[string]bool{}
+	forEachPageBlock(page.Root.Content, func(block *notionapi.Block) {
+		if block.Type != notionapi.BlockCollectionView {
+			return
+		}
+		for _, view := range block.CollectionViews {
+			if view.Collection == nil {
+				continue
+			}
+			schema := view.Collection.CollectionSchema
+			for _, row := range view.CollectionRows {
+				id := normalizeID(row.ID)
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				props := map[string]string{}
+				for propID, v := range row.Properties {
+					col := schema[propID]
+					// title is the title of the row's page
+					if col == nil || col.Type == "title" {
+						continue
+					}
+					props[collectionPropKey(col.Name)] = collectionPropValue(v)
+				}
+				r := &collectionRow{
+					pageID: id,
+					props:  props,
+				}
+				res = append(res, r)
+			}
+		}
+	})
+	return res
+}
+
+// loadCollectionPages loads pages of rows of collections in pageID (and
+// their sub-pages) into idToPage with loadPage. Returns rows keyed by page id
+func loadCollectionPages(pageID string, idToPage map[string]*notionapi.Page, idToParentID map[string]string, loadPage func(pageID string, n int) (*notionapi.Page, error)) map[string]*collectionRow {
+	pages, _ := loadAllPages([]string{pageID}, loadPage)
+	page := pages[pageID]
+	panicIf(page == nil, "failed to load collection page %s", pageID)
+
+	rows := findCollectionRows(page)
+	lg("Collection page %s has %d rows\n", pageID, len(rows))
+	res := map[string]*collectionRow{}
+	var rowIDs []string
+	for _, row := range rows {
+		res[row.pageID] = row
+		rowIDs = append(rowIDs, row.pageID)
+	}
+	rowPages, rowParentIDs := loadAllPages(rowIDs, loadPage)
+	for id, page := range rowPages {
+		idToPage[id] = page
+	}
+	for id, parentID := range rowParentIDs {
+		idToParentID[id] = parentID
+	}
+	for _, id := range rowIDs {
+		idToParentID[id] = pageID
+	}
+	return res
+}
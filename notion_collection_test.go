@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionRowsToArticles(t *testing.T) {
+	pageID := "5a1b0c3d4e5f40718293a4b5c6d7e8f9"
+	page := loadPageFromCache("testdata/notion_collection", pageID)
+	assert.NotNil(t, page)
+
+	rows := findCollectionRows(page)
+	assert.Equal(t, 2, len(rows))
+	row := rows[0]
+	assert.Equal(t, "1f2e3d4c5b6a49788695a4b3c2d1e0f1", row.pageID)
+	exp := map[string]string{
+		"date":        "2019-03-04",
+		"tags":        "go,programming",
+		"status":      "normal",
+		"notes":       "not metadata",
+		"description": "A short summary",
+	}
+	assert.Equal(t, exp, row.props)
+
+	idToPage := map[string]*notionapi.Page{}
+	idToRow := map[string]*collectionRow{}
+	for _, row := range rows {
+		rowPage := mkTestPage(row.pageID)
+		rowPage.Root.Content = []*notionapi.Block{
+			mkTestBlock("t1", notionapi.BlockText, "Post text"),
+		}
+		idToPage[row.pageID] = rowPage
+		idToRow[row.pageID] = row
+	}
+	// a page that is not in the collection
+	otherID := mkTestPageID(7)
+	idToPage[otherID] = mkTestPage(otherID)
+
+	store := articlesFromPages(nil, idToPage, nil, idToRow)
+	assert.Equal(t, 3, len(store.articles))
+
+	a1 := store.idToArticle[rows[0].pageID]
+	assert.True(t, a1.IsBlog())
+	assert.Equal(t, "2019-03-04", a1.PublishedOn.Format("2006-01-02"))
+	assert.Equal(t, []string{"go", "programming"}, a1.Tags)
+	assert.Equal(t, statusNormal, a1.Status)
+	assert.Equal(t, "A short summary", a1.Description)
+
+	a2 := store.idToArticle[rows[1].pageID]
+	assert.True(t, a2.IsBlog())
+	assert.Equal(t, "2018-11-20", a2.PublishedOn.Format("2006-01-02"))
+	assert.Equal(t, []string{"notion"}, a2.Tags)
+	assert.Equal(t, statusHidden, a2.Status)
+
+	assert.False(t, store.idToArticle[otherID].IsBlog())
+	assert.Equal(t, []*Article{a1}, store.getBlogNotHidden())
+}
+
+func TestCollectionPropKey(t *testing.T) {
+	assert.Equal(t, "publishedon", collectionPropKey("Published On"))
+	assert.Equal(t, "headerimage", collectionPropKey(" Header  image"))
+	assert.Equal(t, "tags", collectionPropKey("Tags"))
+}
+
+func TestLoadCollectionPages(t *testing.T) {
+	pageID := "5a1b0c3d4e5f40718293a4b5c6d7e8f9"
+	collectionPage := loadPageFromCache("testdata/notion_collection", pageID)
+	assert.NotNil(t, collectionPage)
+
+	// pages are loaded with the loader shared with loading of other pages
+	var mu sync.Mutex
+	loaded := map[string]int{}
+	loadPage := func(id string, n int) (*notionapi.Page, error) {
+		mu.Lock()
+		loaded[id]++
+		mu.Unlock()
+		if id == pageID {
+			return collectionPage, nil
+		}
+		return mkTestPage(id), nil
+	}
+	idToPage := map[string]*notionapi.Page{}
+	idToParentID := map[string]string{}
+	idToRow := loadCollectionPages(pageID, idToPage, idToParentID, loadPage)
+	assert.Equal(t, 2, len(idToRow))
+	rowID := "1f2e3d4c5b6a49788695a4b3c2d1e0f1"
+	assert.NotNil(t, idToRow[rowID])
+	assert.NotNil(t, idToPage[rowID])
+	assert.Equal(t, pageID, idToParentID[rowID])
+	assert.Equal(t, 1, loaded[pageID])
+	assert.Equal(t, 1, loaded[rowID])
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kjk/notionapi"
@@ -23,13 +27,39 @@ var (
 
 	cacheDir     = "notion_cache"
 	notionLogDir = "log"
+
+	// delay before the first retry of a failed download. Doubles with each retry
+	downloadRetryDelay = 500 * time.Millisecond
+
+	errNoNotionToken = errors.New("notion token is needed to download pages that are not in the cache. Get the value of 'token_v2' cookie from a browser logged into notion.so and set NOTION_TOKEN environment variable or use -token flag")
 )
 
+// loadNotionToken returns notion token from -token flag or NOTION_TOKEN
+// environment variable
+func loadNotionToken() (string, error) {
+	token := flgToken
+	if token == "" {
+		token = os.Getenv("NOTION_TOKEN")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errNoNotionToken
+	}
+	return token, nil
+}
+
 // convert 2131b10c-ebf6-4938-a127-7089ff02dbe4 to 2131b10cebf64938a1277089ff02dbe4
 func normalizeID(s string) string {
 	return notionapi.ToNoDashID(s)
 }
 
+// https://www.notion.so/Advanced-web-spidering-with-Puppeteer-ea07db1b9bff415ab180b0525f3898f6
+// =>
+// ea07db1b9bff415ab180b0525f3898f6
+func extractNotionIDFromURL(uri string) string {
+	return notionapi.ExtractNoDashIDFromNotionURL(uri)
+}
+
 func openLogFileForPageID(pageID string) (io.WriteCloser, error) {
 	if !logNotionRequests {
 		return nil, nil
@@ -76,8 +106,24 @@ func findSubPageIDs(blocks []*notionapi.Block) []string {
 	return res
 }
 
+// isCacheExpired returns true if cached file is older than -cache-ttl
+func isCacheExpired(path string) bool {
+	if flgCacheTTL <= 0 {
+		return false
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return time.Since(st.ModTime()) > flgCacheTTL
+}
+
 func loadPageFromCache(dir, pageID string) *notionapi.Page {
 	cachedPath := filepath.Join(dir, pageID+".json")
+	if isCacheExpired(cachedPath) {
+		verbose("Page %s: cache older than %s, ignoring\n", pageID, flgCacheTTL)
+		return nil
+	}
 	d, err := ioutil.ReadFile(cachedPath)
 	if err != nil {
 		return nil
@@ -89,21 +135,42 @@ func loadPageFromCache(dir, pageID string) *notionapi.Page {
 	return &page
 }
 
-// I got "connection reset by peer" error once so retry download 3 times, with a short sleep in-between
-func downloadPageRetry(c *notionapi.Client, pageID string) (*notionapi.Page, error) {
+// errors like 404 will not go away if we retry
+func isRetryableError(err error) bool {
+	s := err.Error()
+	if strings.Contains(s, "non-200 status code of 4") {
+		// 429 is "too many requests"
+		return strings.Contains(s, "status code of 429")
+	}
+	return true
+}
+
+// retryDownloadPage calls download up to maxAttempts times, with exponential
+// backoff between the attempts, until it succeeds
+func retryDownloadPage(pageID string, maxAttempts int, download func() (*notionapi.Page, error)) (*notionapi.Page, error) {
 	var res *notionapi.Page
 	var err error
-	for i := 0; i < 3; i++ {
-		if i > 0 {
-			lg("Download %s failed with '%s'\n", pageID, err)
-			time.Sleep(3 * time.Second) // not sure if it matters
-		}
-		res, err = c.DownloadPage(pageID)
+	delay := downloadRetryDelay
+	for i := 1; ; i++ {
+		res, err = download()
 		if err == nil {
 			return res, nil
 		}
+		lg("Download %s failed with '%s' (attempt %d of %d)\n", pageID, err, i, maxAttempts)
+		if i >= maxAttempts || !isRetryableError(err) {
+			return nil, err
+		}
+		time.Sleep(delay)
+		delay *= 2
 	}
-	return nil, err
+}
+
+// I got "connection reset by peer" error once so retry download a few times
+func downloadPageRetry(c *notionapi.Client, pageID string) (*notionapi.Page, error) {
+	download := func() (*notionapi.Page, error) {
+		return c.DownloadPage(pageID)
+	}
+	return retryDownloadPage(pageID, flgDownloadAttempts, download)
 }
 
 func sha1OfLink(link string) string {
@@ -113,7 +180,15 @@ func sha1OfLink(link string) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-var imgFiles []os.FileInfo
+var (
+	imgFiles []os.FileInfo
+	// sha1 of url of images being downloaded => channel closed when
+	// the download is finished
+	imgDownloads = map[string]chan struct{}{}
+	// protects imgFiles, imgHashes and imgDownloads because pages
+	// are converted to html concurrently
+	imgMu sync.Mutex
+)
 
 func findImageInDir(imgDir string, sha1 string) string {
 	if len(imgFiles) == 0 {
@@ -127,17 +202,27 @@ func findImageInDir(imgDir string, sha1 string) string {
 	return ""
 }
 
-func guessExt(fileName string, contentType string) string {
+func guessExt(fileName string, contentType string) (string, error) {
 	ext := strings.ToLower(filepath.Ext(fileName))
 	switch ext {
-	case ".png", ".jpg", ".jpeg":
-		return ext
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp":
+		return ext, nil
 	}
+	// Content-Type might have parameters e.g. "image/svg+xml; charset=utf-8"
+	contentType = strings.TrimSpace(strings.Split(contentType, ";")[0])
 	switch contentType {
 	case "image/png":
-		return ".png"
-	}
-	panic(fmt.Errorf("Didn't find ext for file '%s', content type '%s'\n", fileName, contentType))
+		return ".png", nil
+	case "image/jpeg":
+		return ".jpg", nil
+	case "image/gif":
+		return ".gif", nil
+	case "image/svg+xml":
+		return ".svg", nil
+	case "image/webp":
+		return ".webp", nil
+	}
+	return "", fmt.Errorf("didn't find ext for file '%s', content type '%s'", fileName, contentType)
 }
 
 func downloadImage(c *notionapi.Client, uri string) ([]byte, string, error) {
@@ -146,11 +231,104 @@ func downloadImage(c *notionapi.Client, uri string) ([]byte, string, error) {
 		lg("\n  failed with %s\n", err)
 		return nil, "", err
 	}
-	ext := guessExt(uri, img.Header.Get("Content-Type"))
+	ext, err := guessExt(uri, img.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, "", err
+	}
 	return img.Data, ext, nil
 }
 
+// notionURLExpiry returns time when a signed url of a file uploaded to notion
+// (X-Amz-Date + X-Amz-Expires) expires. Returns false if uri is not signed
+func notionURLExpiry(uri string) (time.Time, bool) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return time.Time{}, false
+	}
+	q := u.Query()
+	if q.Get("X-Amz-Expires") == "" && strings.HasPrefix(u.Path, "/image/") {
+		// https://www.notion.so/image/${escaped s3 url}
+		return notionURLExpiry(strings.TrimPrefix(u.Path, "/image/"))
+	}
+	date, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	secs, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date.Add(time.Duration(secs) * time.Second), true
+}
+
+// isExpiredNotionURL returns true if uri is a signed url that can
+// no longer be downloaded
+func isExpiredNotionURL(uri string) bool {
+	expiry, ok := notionURLExpiry(uri)
+	return ok && expiry.Before(time.Now())
+}
+
+// warnIfExpiredURL warns about using an expired url of an image in html
+func warnIfExpiredURL(uri string, pageID string) {
+	if isExpiredNotionURL(uri) {
+		logWarn("Warning: url '%s' in page https://notion.so/%s has expired, re-download the page\n", uri, normalizeID(pageID))
+	}
+}
+
+// imageHashes allows storing images that have the same content but different
+// urls only once. Persisted in cacheDir so that it survives rebuilds
+type imageHashes struct {
+	// sha1 of url => name of file in img directory
+	URLToFile map[string]string `json:"urls"`
+	// sha1 of image data => name of file in img directory
+	HashToFile map[string]string `json:"hashes"`
+}
+
+var imgHashes *imageHashes
+
+func imageHashesPath() string {
+	return filepath.Join(cacheDir, "img_hashes.json")
+}
+
+func loadImageHashes() *imageHashes {
+	if imgHashes != nil {
+		return imgHashes
+	}
+	imgHashes = &imageHashes{
+		URLToFile:  map[string]string{},
+		HashToFile: map[string]string{},
+	}
+	d, err := ioutil.ReadFile(imageHashesPath())
+	if err == nil {
+		err = json.Unmarshal(d, imgHashes)
+		if err != nil {
+			logWarn("Warning: failed to parse %s, ignoring: '%s'\n", imageHashesPath(), err)
+		}
+	}
+	return imgHashes
+}
+
+func saveImageHashes() {
+	d, err := json.MarshalIndent(imgHashes, "", "  ")
+	panicIfErr(err)
+	err = ioutil.WriteFile(imageHashesPath(), d, 0644)
+	panicIfErr(err)
+}
+
 // return path of cached image on disk
+// findCachedImage returns path of already downloaded image with url whose
+// sha1 is sha or "" if not downloaded. Must be called with imgMu locked
+func findCachedImage(imgDir string, sha string) string {
+	if path := findImageInDir(imgDir, sha); path != "" {
+		return path
+	}
+	hashes := loadImageHashes()
+	if name := hashes.URLToFile[sha]; name != "" && fileExists(filepath.Join(imgDir, name)) {
+		return filepath.Join(imgDir, name)
+	}
+	return ""
+}
+
 func downloadAndCacheImage(c *notionapi.Client, uri string) (string, error) {
 	sha := sha1OfLink(uri)
 
@@ -160,16 +338,50 @@ func downloadAndCacheImage(c *notionapi.Client, uri string) (string, error) {
 	err := os.MkdirAll(imgDir, 0755)
 	panicIfErr(err)
 
-	cachedPath := findImageInDir(imgDir, sha)
+	imgMu.Lock()
+	// the same image can be on many pages, wait if it's being downloaded
+	for imgDownloads[sha] != nil {
+		done := imgDownloads[sha]
+		imgMu.Unlock()
+		<-done
+		imgMu.Lock()
+	}
+	cachedPath := findCachedImage(imgDir, sha)
 	if cachedPath != "" {
+		imgMu.Unlock()
 		verbose("Image %s already downloaded as %s\n", uri, cachedPath)
 		return cachedPath, nil
 	}
-
+	done := make(chan struct{})
+	imgDownloads[sha] = done
+	imgMu.Unlock()
+	defer func() {
+		imgMu.Lock()
+		delete(imgDownloads, sha)
+		imgMu.Unlock()
+		close(done)
+	}()
+
+	// download without holding imgMu so that images are downloaded concurrently
 	timeStart := time.Now()
 	lg("Downloading %s ... ", uri)
 
 	imgData, ext, err := downloadImage(c, uri)
+	if err != nil {
+		return "", err
+	}
+
+	imgMu.Lock()
+	defer imgMu.Unlock()
+	hashes := loadImageHashes()
+	contentSha := fmt.Sprintf("%x", sha1.Sum(imgData))
+	if name := hashes.HashToFile[contentSha]; name != "" && fileExists(filepath.Join(imgDir, name)) {
+		hashes.URLToFile[sha] = name
+		saveImageHashes()
+		cachedPath = filepath.Join(imgDir, name)
+		lg("finished in %s. Same as '%s'\n", time.Since(timeStart), cachedPath)
+		return cachedPath, nil
+	}
 
 	cachedPath = filepath.Join(imgDir, sha+ext)
 
@@ -177,6 +389,12 @@ func downloadAndCacheImage(c *notionapi.Client, uri string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if fi, err := os.Stat(cachedPath); err == nil {
+		imgFiles = append(imgFiles, fi)
+	}
+	hashes.URLToFile[sha] = filepath.Base(cachedPath)
+	hashes.HashToFile[contentSha] = filepath.Base(cachedPath)
+	saveImageHashes()
 	lg("finished in %s. Wrote as '%s'\n", time.Since(timeStart), cachedPath)
 
 	return cachedPath, nil
@@ -184,6 +402,9 @@ func downloadAndCacheImage(c *notionapi.Client, uri string) (string, error) {
 
 func downloadAndCachePage(c *notionapi.Client, pageID string) (*notionapi.Page, error) {
 	//verbose("downloading page with id %s\n", pageID)
+	if c.AuthToken == "" {
+		return nil, errNoNotionToken
+	}
 	lf, _ := openLogFileForPageID(pageID)
 	if lf != nil {
 		c.Logger = lf
@@ -253,6 +474,9 @@ func loadPagesFromDisk(dir string) map[string]*notionapi.Page {
 			continue
 		}
 		page := loadPageFromCache(dir, pageID)
+		if page == nil {
+			continue
+		}
 		cachedPagesFromDisk[pageID] = page
 	}
 	lg("loadPagesFromDisk: loaded %d cached pages from %s\n", len(cachedPagesFromDisk), dir)
@@ -365,42 +589,109 @@ func checkIfPagesAreOutdated(c *notionapi.Client, cachedPagesFromDisk map[string
 	return isCachedPageNotOutdated
 }
 
-func loadNotionPages(c *notionapi.Client, indexPageID string, idToPage map[string]*notionapi.Page, useCache bool) {
-	cachedPagesFromDisk := loadPagesFromDisk(cacheDir)
-	isCachedPageNotOutdated := checkIfPagesAreOutdated(c, cachedPagesFromDisk)
-
-	toVisit := []string{indexPageID}
-
-	n := 1
-	for len(toVisit) > 0 {
-		pageID := normalizeID(toVisit[0])
-		toVisit = toVisit[1:]
-
-		if _, ok := idToPage[pageID]; ok {
-			continue
+// crawlNotionPages loads startIDs and, recursively, all their sub-pages
+// into idToPage. Up to concurrency pages are loaded at the same time.
+// If idToParentID is not nil, it records the id of the page that contains
+// a given sub-page. If loadPage returns nil page, the page is skipped
+func crawlNotionPages(startIDs []string, idToPage map[string]*notionapi.Page, idToParentID map[string]string, concurrency int, loadPage func(pageID string, n int) (*notionapi.Page, error)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan bool, concurrency)
+	n := 0
+
+	var visit func(pageID, parentID string)
+	visit = func(pageID, parentID string) {
+		pageID = normalizeID(pageID)
+		mu.Lock()
+		if _, ok := idToPage[pageID]; ok || firstErr != nil {
+			mu.Unlock()
+			return
+		}
+		// mark as visited so that other goroutines don't load it again
+		idToPage[pageID] = nil
+		if idToParentID != nil && parentID != "" {
+			idToParentID[pageID] = parentID
 		}
-
-		page, err := loadNotionPage(c, pageID, useCache, n, isCachedPageNotOutdated, cachedPagesFromDisk)
-		panicIfErr(err)
 		n++
+		pageNo := n
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- true
+			page, err := loadPage(pageID, pageNo)
+			<-sem
+
+			mu.Lock()
+			if err != nil {
+				delete(idToPage, pageID)
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			if page == nil {
+				// page was skipped
+				delete(idToPage, pageID)
+				mu.Unlock()
+				return
+			}
+			idToPage[pageID] = page
+			mu.Unlock()
 
-		idToPage[pageID] = page
+			for _, subPageID := range findSubPageIDs(page.Root.Content) {
+				visit(subPageID, pageID)
+			}
+		}()
+	}
 
-		subPages := findSubPageIDs(page.Root.Content)
-		toVisit = append(toVisit, subPages...)
+	for _, startID := range startIDs {
+		visit(startID, "")
 	}
+	wg.Wait()
+	return firstErr
 }
 
-func loadAllPages(c *notionapi.Client, startIDs []string, useCache bool) map[string]*notionapi.Page {
-	idToPage := map[string]*notionapi.Page{}
-	nPrev := 0
-	for _, startID := range startIDs {
-		loadNotionPages(c, startID, idToPage, useCache)
-		nDownloaded := len(idToPage) - nPrev
-		lg("Downloaded %d pages\n", nDownloaded)
-		nPrev = len(idToPage)
+// newNotionPageLoader returns a function for crawlNotionPages that loads
+// pages from cache or downloads them. Cache is read and checked for
+// outdated pages only once, so the function can be used for many crawls
+func newNotionPageLoader(c *notionapi.Client, useCache bool) func(pageID string, n int) (*notionapi.Page, error) {
+	cachedPagesFromDisk := loadPagesFromDisk(cacheDir)
+	isCachedPageNotOutdated := checkIfPagesAreOutdated(c, cachedPagesFromDisk)
+
+	return func(pageID string, n int) (*notionapi.Page, error) {
+		// downloadAndCachePage() sets client.Logger so each goroutine
+		// needs its own copy of the client
+		client := *c
+		var page *notionapi.Page
+		var err error
+		processPage(pageID, func() {
+			page, err = loadNotionPage(&client, pageID, useCache, n, isCachedPageNotOutdated, cachedPagesFromDisk)
+		})
+		if err != nil && flgKeepGoing {
+			// skip the page instead of stopping the crawl
+			addPageFailure(pageID, err)
+			return nil, nil
+		}
+		return page, err
 	}
-	return idToPage
+}
+
+// loadAllPages returns all pages reachable from startIDs and ids of
+// their parent pages
+func loadAllPages(startIDs []string, loadPage func(pageID string, n int) (*notionapi.Page, error)) (map[string]*notionapi.Page, map[string]string) {
+	idToPage := map[string]*notionapi.Page{}
+	idToParentID := map[string]string{}
+	err := crawlNotionPages(startIDs, idToPage, idToParentID, flgConcurrency, loadPage)
+	panicIfErr(err)
+	lg("Downloaded %d pages\n", len(idToPage))
+	return idToPage, idToParentID
 }
 
 func rmFile(path string) {
@@ -416,6 +707,21 @@ func rmCached(pageID string) {
 	rmFile(filepath.Join(cacheDir, id+".json"))
 }
 
+// purgeCachedPage removes cached json and log file of a page so that it'll
+// be re-downloaded. pageID can be an id (with or without dashes) or notion url
+func purgeCachedPage(pageID string) error {
+	id := normalizeID(strings.TrimSpace(pageID))
+	if len(id) != 32 {
+		id = extractNotionIDFromURL(pageID)
+	}
+	if len(id) != 32 {
+		return fmt.Errorf("'%s' is not a valid notion page id", pageID)
+	}
+	rmCached(id)
+	lg("Purged cached page %s\n", id)
+	return nil
+}
+
 func createNotionCacheDir() {
 	err := os.MkdirAll(cacheDir, 0755)
 	panicIfErr(err)
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func mkTestPageID(n int) string {
+	return fmt.Sprintf("%032d", n)
+}
+
+// returns a page whose content links to sub-pages with given ids
+func mkTestPage(id string, subPageIDs ...string) *notionapi.Page {
+	root := &notionapi.Block{
+		ID:    id,
+		Type:  notionapi.BlockPage,
+		Title: "page " + id,
+	}
+	for _, subID := range subPageIDs {
+		b := &notionapi.Block{
+			ID:   subID,
+			Type: notionapi.BlockPage,
+		}
+		root.Content = append(root.Content, b)
+	}
+	return &notionapi.Page{
+		ID:   id,
+		Root: root,
+	}
+}
+
+func TestCrawlNotionPages(t *testing.T) {
+	// 1 => 2, 3; 2 => 4, 5; 3 => 4, 1; 5 => 6
+	pages := map[string]*notionapi.Page{}
+	add := func(id int, subIDs ...int) {
+		var ids []string
+		for _, subID := range subIDs {
+			ids = append(ids, mkTestPageID(subID))
+		}
+		pages[mkTestPageID(id)] = mkTestPage(mkTestPageID(id), ids...)
+	}
+	add(1, 2, 3)
+	add(2, 4, 5)
+	add(3, 4, 1)
+	add(4)
+	add(5, 6)
+	add(6)
+
+	var mu sync.Mutex
+	nVisits := map[string]int{}
+	loadPage := func(pageID string, n int) (*notionapi.Page, error) {
+		mu.Lock()
+		nVisits[pageID]++
+		mu.Unlock()
+		return pages[pageID], nil
+	}
+
+	idToPage := map[string]*notionapi.Page{}
+	idToParentID := map[string]string{}
+	err := crawlNotionPages([]string{mkTestPageID(1)}, idToPage, idToParentID, 4, loadPage)
+	assert.NoError(t, err)
+	assert.Equal(t, len(pages), len(idToPage))
+	for id, page := range pages {
+		assert.Equal(t, page, idToPage[id])
+		assert.Equal(t, 1, nVisits[id], "page %s", id)
+	}
+	assert.Equal(t, "", idToParentID[mkTestPageID(1)])
+	assert.Equal(t, mkTestPageID(1), idToParentID[mkTestPageID(2)])
+	assert.Equal(t, mkTestPageID(2), idToParentID[mkTestPageID(5)])
+	assert.Equal(t, mkTestPageID(5), idToParentID[mkTestPageID(6)])
+}
+
+func TestLoadNotionToken(t *testing.T) {
+	prevToken, prevEnv := flgToken, os.Getenv("NOTION_TOKEN")
+	defer func() {
+		flgToken = prevToken
+		os.Setenv("NOTION_TOKEN", prevEnv)
+	}()
+
+	flgToken = ""
+	os.Setenv("NOTION_TOKEN", " env-token\n")
+	token, err := loadNotionToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "env-token", token)
+
+	// flag over-writes env variable
+	flgToken = "flag-token"
+	token, err = loadNotionToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "flag-token", token)
+
+	flgToken = ""
+	os.Setenv("NOTION_TOKEN", "")
+	_, err = loadNotionToken()
+	assert.Equal(t, errNoNotionToken, err)
+	assert.Contains(t, err.Error(), "set NOTION_TOKEN environment variable or use -token flag")
+
+	// without a token we don't try to download
+	_, err = downloadAndCachePage(&notionapi.Client{}, mkTestPageID(1))
+	assert.Equal(t, errNoNotionToken, err)
+}
+
+func TestRetryDownloadPage(t *testing.T) {
+	prevDelay := downloadRetryDelay
+	downloadRetryDelay = time.Millisecond
+	defer func() {
+		downloadRetryDelay = prevDelay
+	}()
+
+	id := mkTestPageID(1)
+	page := mkTestPage(id)
+	nCalls := 0
+	failTwice := func() (*notionapi.Page, error) {
+		nCalls++
+		if nCalls <= 2 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return page, nil
+	}
+	got, err := retryDownloadPage(id, 3, failTwice)
+	assert.NoError(t, err)
+	assert.Equal(t, page, got)
+	assert.Equal(t, 3, nCalls)
+
+	nCalls = 0
+	got, err = retryDownloadPage(id, 2, failTwice)
+	assert.Error(t, err)
+	assert.Nil(t, got)
+	assert.Equal(t, 2, nCalls)
+
+	nCalls = 0
+	notFound := func() (*notionapi.Page, error) {
+		nCalls++
+		return nil, errors.New("http.Post('https://www.notion.so/api/v3/loadPageChunk') returned non-200 status code of 404")
+	}
+	_, err = retryDownloadPage(id, 3, notFound)
+	assert.Error(t, err)
+	assert.Equal(t, 1, nCalls)
+}
+
+func TestPurgeCachedPage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_purge")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir, prevLogDir := cacheDir, notionLogDir
+	cacheDir = filepath.Join(dir, "cache")
+	notionLogDir = filepath.Join(dir, "log")
+	defer func() {
+		cacheDir, notionLogDir = prevCacheDir, prevLogDir
+	}()
+
+	id := "ea07db1b9bff415ab180b0525f3898f6"
+	otherID := mkTestPageID(2)
+	var paths []string
+	for _, pageID := range []string{id, otherID} {
+		cachePath := filepath.Join(cacheDir, pageID+".json")
+		logPath := filepath.Join(notionLogDir, pageID+".go.log.txt")
+		for _, path := range []string{cachePath, logPath} {
+			err = mkdirForFile(path)
+			assert.NoError(t, err)
+			err = ioutil.WriteFile(path, []byte("{}"), 0644)
+			assert.NoError(t, err)
+			paths = append(paths, path)
+		}
+	}
+
+	err = purgeCachedPage("ea07db1b-9bff-415a-b180-b0525f3898f6")
+	assert.NoError(t, err)
+	assert.False(t, fileExists(paths[0]))
+	assert.False(t, fileExists(paths[1]))
+	assert.True(t, fileExists(paths[2]))
+	assert.True(t, fileExists(paths[3]))
+
+	err = purgeCachedPage("https://www.notion.so/Some-title-" + otherID)
+	assert.NoError(t, err)
+	assert.False(t, fileExists(paths[2]))
+	assert.False(t, fileExists(paths[3]))
+
+	err = purgeCachedPage("not-an-id")
+	assert.Error(t, err)
+}
+
+func TestLoadPageFromCacheTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_cache_ttl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevTTL := flgCacheTTL
+	defer func() {
+		flgCacheTTL = prevTTL
+	}()
+
+	id := mkTestPageID(1)
+	path := filepath.Join(dir, id+".json")
+	d, err := json.Marshal(mkTestPage(id))
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(path, d, 0644)
+	assert.NoError(t, err)
+	old := time.Now().Add(-48 * time.Hour)
+	err = os.Chtimes(path, old, old)
+	assert.NoError(t, err)
+
+	// 0 means cache forever
+	flgCacheTTL = 0
+	assert.NotNil(t, loadPageFromCache(dir, id))
+
+	flgCacheTTL = 72 * time.Hour
+	assert.NotNil(t, loadPageFromCache(dir, id))
+
+	flgCacheTTL = 24 * time.Hour
+	assert.Nil(t, loadPageFromCache(dir, id))
+	assert.Empty(t, loadPagesFromDisk(dir))
+}
+
+func TestCrawlNotionPagesMultipleRoots(t *testing.T) {
+	// 1 => 2; 3 => 4, 2
+	pages := map[string]*notionapi.Page{
+		mkTestPageID(1): mkTestPage(mkTestPageID(1), mkTestPageID(2)),
+		mkTestPageID(2): mkTestPage(mkTestPageID(2)),
+		mkTestPageID(3): mkTestPage(mkTestPageID(3), mkTestPageID(4), mkTestPageID(2)),
+		mkTestPageID(4): mkTestPage(mkTestPageID(4)),
+	}
+	loadPage := func(pageID string, n int) (*notionapi.Page, error) {
+		return pages[pageID], nil
+	}
+
+	roots, err := parseRootPageIDs(" " + mkTestPageID(1) + ",https://www.notion.so/Other-root-" + mkTestPageID(3) + "," + mkTestPageID(1))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{mkTestPageID(1), mkTestPageID(3)}, roots)
+
+	idToPage := map[string]*notionapi.Page{}
+	err = crawlNotionPages(roots, idToPage, nil, 2, loadPage)
+	assert.NoError(t, err)
+	assert.Equal(t, len(pages), len(idToPage))
+	for id, page := range pages {
+		assert.Equal(t, page, idToPage[id])
+	}
+
+	_, err = parseRootPageIDs("not-an-id")
+	assert.Error(t, err)
+	_, err = parseRootPageIDs(" , ")
+	assert.Error(t, err)
+}
+
+func TestIsExpiredNotionURL(t *testing.T) {
+	signedURL := func(date time.Time, expires int) string {
+		return fmt.Sprintf("https://s3-us-west-2.amazonaws.com/secure.notion-static.com/a1b2/image.png?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Date=%s&X-Amz-Expires=%d&X-Amz-Signature=abcd", date.UTC().Format("20060102T150405Z"), expires)
+	}
+	now := time.Now()
+	expired := signedURL(now.Add(-2*time.Hour), 3600)
+	fresh := signedURL(now.Add(-30*time.Minute), 3600)
+	assert.True(t, isExpiredNotionURL(expired))
+	assert.False(t, isExpiredNotionURL(fresh))
+
+	// signed url proxied by notion
+	assert.True(t, isExpiredNotionURL("https://www.notion.so/image/"+url.QueryEscape(expired)))
+	assert.False(t, isExpiredNotionURL("https://www.notion.so/image/"+url.QueryEscape(fresh)))
+
+	// not signed urls don't expire
+	assert.False(t, isExpiredNotionURL("https://s3-us-west-2.amazonaws.com/secure.notion-static.com/a1b2/image.png"))
+	assert.False(t, isExpiredNotionURL("https://blog.kowalczyk.info/img/foo.png"))
+	assert.False(t, isExpiredNotionURL("https://example.com/a.png?X-Amz-Date=bad&X-Amz-Expires=3600"))
+}
+
+func TestDownloadAndCacheImageConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	nRequests := map[string]int{}
+	timedOut := false
+	// the first download only finishes when the second has started
+	bothStarted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		nRequests[r.URL.Path]++
+		if len(nRequests) == 2 && nRequests[r.URL.Path] == 1 {
+			close(bothStarted)
+		}
+		mu.Unlock()
+		select {
+		case <-bothStarted:
+		case <-time.After(2 * time.Second):
+			mu.Lock()
+			timedOut = true
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("data of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	var wg sync.WaitGroup
+	for _, path := range []string{"/a.png", "/b.png", "/a.png", "/b.png"} {
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			_, err := downloadAndCacheImage(&notionapi.Client{}, uri)
+			assert.NoError(t, err)
+		}(srv.URL + path)
+	}
+	wg.Wait()
+	// different images are downloaded at the same time, each only once
+	assert.False(t, timedOut)
+	assert.Equal(t, map[string]int{"/a.png": 1, "/b.png": 1}, nRequests)
+}
@@ -41,37 +41,7 @@ func testNotionToHTMLOnePage(c *notionapi.Client, id string) {
 	id = normalizeID(id)
 	article := loadPageAsArticle(c, id)
 
-	canonicalURL := netlifyRequestGetFullHost() + article.URL()
-	model := struct {
-		AnalyticsCode      string
-		Article            *Article
-		CanonicalURL       string
-		CoverImage         string
-		PageTitle          string
-		TagsDisplay        string
-		HeaderImageURL     string
-		NotionEditURL      string
-		Description        string
-		TwitterShareURL    string
-		FacebookShareURL   string
-		LinkedInShareURL   string
-		GooglePlusShareURL string
-	}{
-		AnalyticsCode:      analyticsCode,
-		Article:            article,
-		CanonicalURL:       canonicalURL,
-		CoverImage:         article.HeaderImageURL,
-		PageTitle:          article.Title,
-		Description:        article.Description,
-		TwitterShareURL:    makeTwitterShareURL(article),
-		FacebookShareURL:   makeFacebookShareURL(article),
-		LinkedInShareURL:   makeLinkedinShareURL(article),
-		GooglePlusShareURL: makeGooglePlusShareURL(article),
-	}
-	if article.page != nil {
-		id := normalizeID(article.page.ID)
-		model.NotionEditURL = "https://notion.so/" + id
-	}
+	model := makeArticleModel(article)
 
 	var buf bytes.Buffer
 	err := templates.ExecuteTemplate(&buf, tmplArticle, model)
@@ -1,14 +1,68 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"html"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/kjk/notionapi"
 	"github.com/kjk/notionapi/tohtml"
 )
 
+// not defined in notionapi
+const (
+	blockCallout  = "callout"
+	blockEquation = "equation"
+	// synced block: the original holds the content, copies reference it
+	blockSyncedContainer = "transclusion_container"
+	blockSyncedReference = "transclusion_reference"
+	// a row of a simple table, cells are in properties keyed by column id
+	blockTableRow = "table_row"
+)
+
+// block types tohtml doesn't know about, see maskBlocks
+var maskedBlockTypes = []string{blockCallout, blockEquation, blockSyncedContainer, blockSyncedReference, notionapi.BlockTable, blockTableRow}
+
+// maps Notion text colors and background colors (inline Highlight
+// attribute) to css colors
+var notionColors = map[string]string{
+	"gray":              "#9b9a97",
+	"brown":             "#64473a",
+	"orange":            "#d9730d",
+	"yellow":            "#dfab01",
+	"teal":              "#0f7b6c",
+	"blue":              "#0b6e99",
+	"purple":            "#6940a5",
+	"pink":              "#ad1a72",
+	"red":               "#e03e3e",
+	"gray_background":   "#ebeced",
+	"brown_background":  "#e9e5e3",
+	"orange_background": "#faebdd",
+	"yellow_background": "#fbf3db",
+	"teal_background":   "#ddedea",
+	"blue_background":   "#ddebf1",
+	"purple_background": "#eae4f2",
+	"pink_background":   "#f4dfeb",
+	"red_background":    "#fbe4e4",
+}
+
+// tohtml ignores inline colors and escapes text so we can't inject
+// <span> into it. Instead we surround highlighted text with those
+// (unescaped) markers and replace them after rendering, see maskHighlights
+const (
+	highlightStart = "\x01"
+	highlightSep   = "\x02"
+	highlightEnd   = "\x03"
+)
+
+var highlightRx = regexp.MustCompile(highlightStart + `([a-z_]+)` + highlightSep + `([^\x01\x03]*)` + highlightEnd)
+
 // ImageMapping keeps track of rewritten image urls (locally cached
 // images in notion)
 type ImageMapping struct {
@@ -22,6 +76,16 @@ type HTMLRenderer struct {
 	notionClient *notionapi.Client
 	idToArticle  func(string) *Article
 	images       []ImageMapping
+	// maps id of a header block to its id attribute in html
+	headerIDs map[string]string
+	// maps id of a masked block to its real type, see maskBlocks
+	maskedTypes map[string]string
+	// original text of highlighted inline blocks, see maskHighlights
+	highlightTexts map[*notionapi.InlineBlock]string
+	// footnote definitions, see footnotes.go
+	footnotes []*Footnote
+	// original content of synced block references, see resolveSyncedBlocks
+	syncedContent map[*notionapi.Block][]*notionapi.Block
 
 	r *tohtml.HTMLRenderer
 }
@@ -30,7 +94,7 @@ type HTMLRenderer struct {
 // =>
 // /article/${id}
 func (r *HTMLRenderer) rewriteURL(uri string) string {
-	id := notionapi.ExtractNoDashIDFromNotionURL(uri)
+	id := extractNotionIDFromURL(uri)
 	if id == "" {
 		return uri
 	}
@@ -55,14 +119,86 @@ func (r *HTMLRenderer) getURLAndTitleForBlock(block *notionapi.Block) (string, s
 	return article.URL(), article.Title
 }
 
-// RenderImage renders BlockImage
+// RenderImage renders BlockImage. Images with a caption are wrapped
+// in <figure> with <figcaption>
 func (r *HTMLRenderer) RenderImage(block *notionapi.Block, entering bool) bool {
+	hasCaption := blockCaption(block) != ""
+	if !entering {
+		if hasCaption {
+			caption := r.r.GetInlineContent(blockCaptionInlines(block))
+			r.r.WriteIndent()
+			r.r.WriteString(`<figcaption>` + caption + `</figcaption>`)
+			r.r.Newline()
+			r.r.WriteIndent()
+			r.r.WriteString(`</figure>`)
+			r.r.Newline()
+		}
+		return true
+	}
+	if hasCaption {
+		r.r.WriteIndent()
+		r.r.WriteString(`<figure class="notion-image">`)
+		r.r.Newline()
+	}
+	r.r.WriteElement(block, "img", r.imageAttrs(block), "", entering)
+	return true
+}
+
+// names that Notion gives to images, they don't describe the image
+var genericImageNames = map[string]bool{
+	"image":    true,
+	"untitled": true,
+}
+
+// imageFileName returns file name of the image without extension
+// e.g. "https://example.com/img/go-logo.png" => "go-logo". Returns ""
+// for generic names like "image.png"
+func imageFileName(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	if strings.HasPrefix(u.Path, "/image/") {
+		// https://www.notion.so/image/${escaped url of the image}
+		return imageFileName(strings.TrimPrefix(u.Path, "/image/"))
+	}
+	name := u.Path[strings.LastIndex(u.Path, "/")+1:]
+	name = strings.TrimSpace(strings.TrimSuffix(name, filepath.Ext(name)))
+	if genericImageNames[strings.ToLower(name)] {
+		return ""
+	}
+	return name
+}
+
+// imageAlt returns alt text of image block: the caption or file name of
+// the image. Missing alt text is an error with -strict-alt
+func (r *HTMLRenderer) imageAlt(block *notionapi.Block) string {
+	alt := blockCaption(block)
+	if alt == "" {
+		alt = imageFileName(block.Source)
+	}
+	if alt == "" {
+		panicIf(flgStrictAlt, "image %s in page https://notion.so/%s has no alt text (no caption or file name)", block.ID, normalizeID(r.page.ID))
+		logWarn("Warning: image %s in page https://notion.so/%s has no alt text (no caption or file name)\n", block.ID, normalizeID(r.page.ID))
+	}
+	return html.EscapeString(alt)
+}
+
+// imageAttrs returns attributes of <img> for image block, downloading
+// the image if necessary
+func (r *HTMLRenderer) imageAttrs(block *notionapi.Block) []string {
 	link := block.Source
+	alt := r.imageAlt(block)
 	path, err := downloadAndCacheImage(r.notionClient, link)
 	if err != nil {
-		lg("genImage: downloadAndCacheImage('%s') from page https://notion.so/%s failed with '%s'\n", link, normalizeID(r.page.ID), err)
-		panicIfErr(err)
-		return false
+		// not fatal, we use the original url which hopefully still works
+		logWarn("Warning: downloadAndCacheImage('%s') from page https://notion.so/%s failed with '%s'\n", link, normalizeID(r.page.ID), err)
+		warnIfExpiredURL(link, r.page.ID)
+		attrs := []string{"class", "blog-img", "src", link, "alt", alt}
+		if flgLazyImages {
+			attrs = append(attrs, "loading", "lazy")
+		}
+		return attrs
 	}
 	relURL := "/img/" + filepath.Base(path)
 	im := ImageMapping{
@@ -70,9 +206,20 @@ func (r *HTMLRenderer) RenderImage(block *notionapi.Block, entering bool) bool {
 		relativeURL: relURL,
 	}
 	r.images = append(r.images, im)
-	attrs := []string{"class", "blog-img", "src", relURL}
-	r.r.WriteElement(block, "img", attrs, "", entering)
-	return true
+	attrs := []string{"class", "blog-img", "src", relURL, "alt", alt}
+	if flgLazyImages {
+		attrs = append(attrs, "loading", "lazy")
+	}
+	if flgResizeImages {
+		variants, err := genImageVariants(path)
+		if err != nil {
+			logWarn("Warning: genImageVariants('%s') failed with '%s'\n", path, err)
+		}
+		if len(variants) > 1 {
+			attrs = append(attrs, "srcset", imageSrcset(variants), "sizes", imageSizes(variants))
+		}
+	}
+	return attrs
 }
 
 // RenderPage renders BlockPage
@@ -110,33 +257,374 @@ func (r *HTMLRenderer) RenderCode(block *notionapi.Block, entering bool) bool {
 	// <pre class="%s">
 	// %s
 	// </pre>`, levelCls, block.CodeLanguage, levelCls, code)
-	if entering {
+	if !entering {
+		return true
+	}
+	// the button is handled by www/js/copy.js
+	copyButton := `<button class="copy-code" type="button">Copy</button>`
+	caption := blockCaption(block)
+	if caption == "" {
+		r.r.WriteString(`<div class="notion-code">` + copyButton)
 		htmlHighlight(r.r.Buf, string(block.Code), block.CodeLanguage, "")
+		r.r.WriteString("</div>\n")
+		return true
+	}
+	// caption is usually a file name, data-filename is for copy button
+	caption = html.EscapeString(caption)
+	r.r.WriteString(`<figure class="notion-code" data-filename="` + caption + `">`)
+	r.r.WriteString(`<figcaption class="notion-code-caption">` + caption + `</figcaption>` + copyButton)
+	htmlHighlight(r.r.Buf, string(block.Code), block.CodeLanguage, "")
+	r.r.WriteString("</figure>\n")
+	return true
+}
+
+// blockCaptionInlines returns block's caption, which notionapi doesn't parse
+func blockCaptionInlines(block *notionapi.Block) []*notionapi.InlineBlock {
+	v, ok := block.Properties["caption"]
+	if !ok {
+		return nil
+	}
+	inlines, err := notionapi.ParseInlineBlocks(v)
+	if err != nil {
+		return nil
+	}
+	return inlines
+}
+
+// blockCaption returns text of block's caption
+func blockCaption(block *notionapi.Block) string {
+	return strings.TrimSpace(inlinesToText(blockCaptionInlines(block)))
+}
+
+// RenderHeaderLevel renders BlockHeader, SubHeader and SubSubHeader
+// with id attribute derived from the text, for linking from table of contents
+func (r *HTMLRenderer) RenderHeaderLevel(block *notionapi.Block, level int, entering bool) bool {
+	el := fmt.Sprintf("h%d", level)
+	cls := fmt.Sprintf("notion-header-%d", level)
+	attrs := []string{"class", cls}
+	id := r.headerIDs[block.ID]
+	if id != "" {
+		attrs = append(attrs, "id", id)
+	}
+	// we don't want notion id, we've set our own
+	prevAddID := r.r.AddIDAttribute
+	r.r.AddIDAttribute = false
+	r.r.WriteElement(block, el, attrs, "", entering)
+	r.r.AddIDAttribute = prevAddID
+	return true
+}
+
+// RenderQuote renders BlockQuote as <blockquote>
+func (r *HTMLRenderer) RenderQuote(block *notionapi.Block, entering bool) bool {
+	attrs := []string{"class", "notion-quote"}
+	r.r.WriteElement(block, "blockquote", attrs, "", entering)
+	return true
+}
+
+// RenderList renders BlockBulletedList as <ul> and BlockNumberedList as <ol>.
+// Consecutive items of the same type share a list. Children are rendered
+// inside <li> so nested lists (also of a different type) keep the hierarchy
+func (r *HTMLRenderer) RenderList(block *notionapi.Block, listTag string, entering bool) bool {
+	cls := "notion-bulleted-list"
+	if block.Type == notionapi.BlockNumberedList {
+		cls = "notion-numbered-list"
+	}
+	if entering {
+		if !r.r.IsPrevBlockOfType(block.Type) {
+			r.r.WriteIndent()
+			r.r.WriteString(`<` + listTag + ` class="` + cls + `">`)
+			r.r.Newline()
+			r.r.Level++
+		}
+		attrs := []string{"class", cls}
+		r.r.WriteElement(block, "li", attrs, "", entering)
+		return true
+	}
+	r.r.WriteElement(block, "li", nil, "", entering)
+	if !r.r.IsNextBlockOfType(block.Type) {
+		r.r.Level--
+		r.r.WriteIndent()
+		r.r.WriteString(`</` + listTag + `>`)
+		r.r.Newline()
+	}
+	return true
+}
+
+// format of callout block
+type formatCallout struct {
+	// emoji like "💡" or url of an image
+	PageIcon string `json:"page_icon"`
+}
+
+func calloutIcon(block *notionapi.Block) string {
+	var f formatCallout
+	if len(block.FormatRaw) == 0 || json.Unmarshal(block.FormatRaw, &f) != nil {
+		return ""
+	}
+	return f.PageIcon
+}
+
+// RenderCallout renders callout block as a div with its icon
+func (r *HTMLRenderer) RenderCallout(block *notionapi.Block, entering bool) bool {
+	content := ""
+	if icon := calloutIcon(block); icon != "" {
+		if strings.HasPrefix(icon, "http") {
+			icon = fmt.Sprintf(`<img src="%s">`, r.rewriteURL(icon))
+		} else {
+			icon = html.EscapeString(icon)
+		}
+		content = `<span class="notion-callout-icon">` + icon + `</span>`
+	}
+	attrs := []string{"class", "notion-callout"}
+	r.r.WriteElement(block, "div", attrs, content, entering)
+	return true
+}
+
+// RenderEquation renders equation block as LaTeX source in
+// span.math, which is rendered by KaTeX in the browser
+func (r *HTMLRenderer) RenderEquation(block *notionapi.Block, entering bool) bool {
+	if !entering {
+		return true
+	}
+	latex := html.EscapeString(inlinesToText(block.InlineContent))
+	r.r.WriteIndent()
+	r.r.WriteString(`<div class="notion-equation"><span class="math math-display">` + latex + `</span></div>`)
+	r.r.Newline()
+	return true
+}
+
+// format of simple table block
+type formatTable struct {
+	ColumnOrder []string `json:"table_block_column_order"`
+	// if true, the first row is a header
+	ColumnHeader bool `json:"table_block_column_header"`
+}
+
+// RenderTable renders simple table block and its rows as <table>
+func (r *HTMLRenderer) RenderTable(block *notionapi.Block, entering bool) bool {
+	if !entering {
+		return true
 	}
+	var f formatTable
+	if len(block.FormatRaw) > 0 {
+		_ = json.Unmarshal(block.FormatRaw, &f)
+	}
+	genRow := func(row *notionapi.Block, cellTag string) string {
+		s := "<tr>"
+		for _, colID := range f.ColumnOrder {
+			inlines, _ := notionapi.ParseInlineBlocks(row.Properties[colID])
+			s += "<" + cellTag + ">" + r.r.GetInlineContent(inlines) + "</" + cellTag + ">"
+		}
+		return s + "</tr>"
+	}
+
+	rows := block.Content
+	s := `<table class="notion-table">`
+	if f.ColumnHeader && len(rows) > 0 {
+		s += "<thead>" + genRow(rows[0], "th") + "</thead>"
+		rows = rows[1:]
+	}
+	s += "<tbody>"
+	for _, row := range rows {
+		s += genRow(row, "td")
+	}
+	s += "</tbody></table>"
+	r.r.WriteIndent()
+	r.r.WriteString(s)
+	r.r.Newline()
 	return true
 }
 
+// format of synced block reference
+type formatSyncedReference struct {
+	Pointer struct {
+		ID string `json:"id"`
+	} `json:"transclusion_reference_pointer"`
+}
+
+func syncedReferenceID(block *notionapi.Block) string {
+	var f formatSyncedReference
+	if len(block.FormatRaw) == 0 || json.Unmarshal(block.FormatRaw, &f) != nil {
+		return ""
+	}
+	return f.Pointer.ID
+}
+
+func findBlockByID(blocks []*notionapi.Block, id string) *notionapi.Block {
+	for _, block := range blocks {
+		if normalizeID(block.ID) == id {
+			return block
+		}
+		if res := findBlockByID(block.Content, id); res != nil {
+			return res
+		}
+	}
+	return nil
+}
+
+// resolveSyncedBlocks sets content of synced block references to the content
+// of the block they point to, so that it's rendered in place of a reference.
+// References that can't be resolved are skipped. Callers must restore
+// the content with unresolveSyncedBlocks
+func (r *HTMLRenderer) resolveSyncedBlocks(blocks []*notionapi.Block) {
+	for _, block := range blocks {
+		if block.Type != blockSyncedReference {
+			r.resolveSyncedBlocks(block.Content)
+			continue
+		}
+		r.syncedContent[block] = block.Content
+		block.Content = nil
+		id := syncedReferenceID(block)
+		var src *notionapi.Block
+		if id != "" {
+			src = findBlockByID(r.page.Root.Content, normalizeID(id))
+		}
+		switch {
+		case src == nil || src == block || findBlockByID(src.Content, normalizeID(block.ID)) != nil:
+			// not in the page or a reference to its own parent
+			logWarn("Warning: synced block %s in page https://notion.so/%s references block '%s' that can't be resolved\n", block.ID, normalizeID(r.page.ID), id)
+		case src.Type == blockSyncedContainer:
+			block.Content = src.Content
+		default:
+			block.Content = []*notionapi.Block{src}
+		}
+	}
+}
+
+func (r *HTMLRenderer) unresolveSyncedBlocks() {
+	for block, content := range r.syncedContent {
+		block.Content = content
+	}
+	r.syncedContent = map[*notionapi.Block][]*notionapi.Block{}
+}
+
+// tohtml panics on block types it doesn't know about, like callout or
+// equation, so we render them as quote blocks. Callers must restore
+// the type with unmaskBlocks
+func (r *HTMLRenderer) maskBlocks(blocks []*notionapi.Block) {
+	for _, block := range blocks {
+		for _, blockType := range maskedBlockTypes {
+			if block.Type == blockType {
+				block.Type = notionapi.BlockQuote
+				r.maskedTypes[block.ID] = blockType
+			}
+		}
+		r.maskBlocks(block.Content)
+	}
+}
+
+func (r *HTMLRenderer) unmaskBlocks(blocks []*notionapi.Block) {
+	for _, block := range blocks {
+		if blockType := r.maskedTypes[block.ID]; blockType != "" {
+			block.Type = blockType
+		}
+		r.unmaskBlocks(block.Content)
+	}
+}
+
+// maskHighlights marks text of inline blocks that have a known color so
+// that genHighlights can turn them into <span>. Callers must restore
+// the text with unmaskHighlights
+func (r *HTMLRenderer) maskHighlights(blocks []*notionapi.Block) {
+	for _, block := range blocks {
+		for _, b := range block.InlineContent {
+			if notionColors[b.Highlight] == "" {
+				continue
+			}
+			// synced blocks share content with their source so we can
+			// see the same inline block more than once
+			if _, ok := r.highlightTexts[b]; ok {
+				continue
+			}
+			r.highlightTexts[b] = b.Text
+			b.Text = highlightStart + b.Highlight + highlightSep + b.Text + highlightEnd
+		}
+		r.maskHighlights(block.Content)
+	}
+}
+
+func (r *HTMLRenderer) unmaskHighlights() {
+	for b, text := range r.highlightTexts {
+		b.Text = text
+	}
+	r.highlightTexts = map[*notionapi.InlineBlock]string{}
+}
+
+// genHighlights replaces markers added by maskHighlights with <span>
+func genHighlights(s string) string {
+	return highlightRx.ReplaceAllStringFunc(s, func(m string) string {
+		parts := highlightRx.FindStringSubmatch(m)
+		color, text := parts[1], parts[2]
+		prop := "color"
+		if strings.HasSuffix(color, "_background") {
+			prop = "background-color"
+		}
+		return fmt.Sprintf(`<span style="%s:%s">%s</span>`, prop, notionColors[color], text)
+	})
+}
+
 func (r *HTMLRenderer) blockRenderOverride(block *notionapi.Block, entering bool) bool {
 	switch block.Type {
+	case notionapi.BlockText:
+		// footnote definitions are rendered in references section
+		return r.isFootnoteBlock(block)
+	case notionapi.BlockQuote:
+		switch r.maskedTypes[block.ID] {
+		case blockCallout:
+			return r.RenderCallout(block, entering)
+		case blockEquation:
+			return r.RenderEquation(block, entering)
+		case blockSyncedContainer, blockSyncedReference:
+			// only the content is rendered
+			return true
+		case notionapi.BlockTable:
+			return r.RenderTable(block, entering)
+		case blockTableRow:
+			// rendered by RenderTable
+			return true
+		}
+		return r.RenderQuote(block, entering)
+	case notionapi.BlockHeader:
+		return r.RenderHeaderLevel(block, 1, entering)
+	case notionapi.BlockSubHeader:
+		return r.RenderHeaderLevel(block, 2, entering)
+	case notionapi.BlockSubSubHeader:
+		return r.RenderHeaderLevel(block, 3, entering)
+	case notionapi.BlockBulletedList:
+		return r.RenderList(block, "ul", entering)
+	case notionapi.BlockNumberedList:
+		return r.RenderList(block, "ol", entering)
 	case notionapi.BlockPage:
 		return r.RenderPage(block, entering)
 	case notionapi.BlockCode:
 		return r.RenderCode(block, entering)
 	case notionapi.BlockImage:
 		return r.RenderImage(block, entering)
+	case notionapi.BlockBookmark:
+		return r.RenderBookmark(block, entering)
+	case notionapi.BlockEmbed, notionapi.BlockTweet:
+		return r.RenderEmbed(block, entering)
 	}
 	return false
 }
 
+var panicOnFailuresOnce sync.Once
+
 // NewHTMLRenderer returns new HTMLGenerator
 func NewHTMLRenderer(c *notionapi.Client, page *notionapi.Page) *HTMLRenderer {
 	res := &HTMLRenderer{
-		notionClient: c,
-		page:         page,
+		notionClient:   c,
+		page:           page,
+		maskedTypes:    map[string]string{},
+		highlightTexts: map[*notionapi.InlineBlock]string{},
+		syncedContent:  map[*notionapi.Block][]*notionapi.Block{},
 	}
 
 	r := tohtml.NewHTMLRenderer(page)
-	notionapi.PanicOnFailures = true
+	// pages are rendered concurrently so only set it once
+	panicOnFailuresOnce.Do(func() {
+		notionapi.PanicOnFailures = true
+	})
 	r.AddIDAttribute = true
 	r.RenderBlockOverride = res.blockRenderOverride
 	r.RewriteURL = res.rewriteURL
@@ -145,14 +633,145 @@ func NewHTMLRenderer(c *notionapi.Client, page *notionapi.Page) *HTMLRenderer {
 	return res
 }
 
+// TocItem is an entry in table of contents
+type TocItem struct {
+	Level int
+	Title string
+	ID    string
+}
+
+func headerLevel(block *notionapi.Block) int {
+	switch block.Type {
+	case notionapi.BlockHeader:
+		return 1
+	case notionapi.BlockSubHeader:
+		return 2
+	case notionapi.BlockSubSubHeader:
+		return 3
+	}
+	return 0
+}
+
+func inlinesToText(blocks []*notionapi.InlineBlock) string {
+	s := ""
+	for _, b := range blocks {
+		s += b.Text
+	}
+	return s
+}
+
+func collectHeaders(blocks []*notionapi.Block, res []*notionapi.Block) []*notionapi.Block {
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		if headerLevel(block) > 0 {
+			res = append(res, block)
+		}
+		// sub-pages are rendered as links so their content is not part of this page
+		if block.Type != notionapi.BlockPage {
+			res = collectHeaders(block.Content, res)
+		}
+	}
+	return res
+}
+
+// buildToc returns table of contents of headers in blocks. It also sets
+// unique ids for header blocks
+func (r *HTMLRenderer) buildToc(blocks []*notionapi.Block) []TocItem {
+	var res []TocItem
+	r.headerIDs = map[string]string{}
+	seen := map[string]bool{}
+	for _, block := range collectHeaders(blocks, nil) {
+		title := strings.TrimSpace(inlinesToText(block.InlineContent))
+		id := urlify(title)
+		if id == "" {
+			id = "header"
+		}
+		// id with a suffix can also be an id of another header
+		// e.g. "Intro 2" and a second "Intro"
+		base := id
+		for n := 2; seen[id]; n++ {
+			id = base + "-" + strconv.Itoa(n)
+		}
+		seen[id] = true
+		r.headerIDs[block.ID] = id
+		item := TocItem{
+			Level: headerLevel(block),
+			Title: title,
+			ID:    id,
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+// genTocHTML generates nested lists for toc items
+func genTocHTML(items []TocItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	minLevel := items[0].Level
+	for _, item := range items {
+		if item.Level < minLevel {
+			minLevel = item.Level
+		}
+	}
+	s := `<div class="toc">`
+	// number of currently open <ul>
+	depth := 0
+	for _, item := range items {
+		level := item.Level - minLevel + 1
+		// nest at most one level deeper because <ul> can't have <ul>
+		// as a child e.g. when h3 follows h1
+		if level > depth+1 {
+			level = depth + 1
+		}
+		if level > depth {
+			for depth < level {
+				s += "<ul>"
+				depth++
+			}
+		} else {
+			s += "</li>"
+			for depth > level {
+				s += "</ul></li>"
+				depth--
+			}
+		}
+		s += fmt.Sprintf(`<li><a href="#%s">%s</a>`, item.ID, html.EscapeString(item.Title))
+	}
+	s += "</li>"
+	for depth > 1 {
+		s += "</ul></li>"
+		depth--
+	}
+	s += "</ul></div>"
+	return s
+}
+
 // Gen returns generated HTML
 func (r *HTMLRenderer) Gen() []byte {
-	inner := string(r.r.ToHTML())
 	page := r.page.Root
+	toc := r.buildToc(page.Content)
+	r.footnotes = collectFootnotes(page.Content)
+	r.resolveSyncedBlocks(page.Content)
+	defer r.unresolveSyncedBlocks()
+	r.maskBlocks(page.Content)
+	defer r.unmaskBlocks(page.Content)
+	r.maskHighlights(page.Content)
+	defer r.unmaskHighlights()
+	inner := string(r.r.ToHTML())
+	inner = r.replaceFootnoteRefs(inner)
+	inner += r.genFootnotesHTML()
+	inner = genHighlights(inner)
 	f := page.FormatPage
 	isMono := f != nil && f.PageFont == "mono"
 
 	s := `<p></p>`
+	if len(toc) > flgTocMinHeaders {
+		s += genTocHTML(toc)
+	}
 	if isMono {
 		s += `<div style="font-family: monospace">`
 	}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func mkTestBlock(id string, blockType string, text string) *notionapi.Block {
+	b := &notionapi.Block{
+		ID:   id,
+		Type: blockType,
+	}
+	if text != "" {
+		b.InlineContent = []*notionapi.InlineBlock{
+			{
+				Text: text,
+			},
+		}
+	}
+	return b
+}
+
+// returns a page with given blocks as content
+func mkTestPageWithBlocks(blocks ...*notionapi.Block) *notionapi.Page {
+	id := mkTestPageID(1)
+	page := mkTestPage(id)
+	page.Root.Content = blocks
+	return page
+}
+
+func renderTestPage(page *notionapi.Page) string {
+	r := NewHTMLRenderer(nil, page)
+	return string(r.Gen())
+}
+
+func TestToc(t *testing.T) {
+	prevMin := flgTocMinHeaders
+	defer func() {
+		flgTocMinHeaders = prevMin
+	}()
+	flgTocMinHeaders = 3
+
+	page := mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "Intro"),
+		mkTestBlock("t1", notionapi.BlockText, "text"),
+		mkTestBlock("h2", notionapi.BlockSubHeader, "Details"),
+		mkTestBlock("h3", notionapi.BlockSubSubHeader, "Deep <dive>"),
+		mkTestBlock("h4", notionapi.BlockHeader, "Intro"),
+	)
+	s := renderTestPage(page)
+	exp := `<div class="toc"><ul>` +
+		`<li><a href="#intro">Intro</a><ul>` +
+		`<li><a href="#details">Details</a><ul>` +
+		`<li><a href="#deep-dive">Deep &lt;dive&gt;</a></li>` +
+		`</ul></li></ul></li>` +
+		`<li><a href="#intro-2">Intro</a></li>` +
+		`</ul></div>`
+	assert.Contains(t, s, exp)
+	assert.Contains(t, s, `<h1 class="notion-header-1" id="intro">`)
+	assert.Contains(t, s, `<h2 class="notion-header-2" id="details">`)
+	assert.Contains(t, s, `<h3 class="notion-header-3" id="deep-dive">`)
+	assert.Contains(t, s, `<h1 class="notion-header-1" id="intro-2">`)
+
+	// not enough headers for toc
+	page = mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "Intro"),
+		mkTestBlock("h2", notionapi.BlockSubHeader, "Details"),
+	)
+	s = renderTestPage(page)
+	assert.NotContains(t, s, `class="toc"`)
+	assert.Contains(t, s, `<h1 class="notion-header-1" id="intro">`)
+
+	// skipped levels are nested only one level deeper and ids of
+	// duplicate headers don't collide with other headers
+	page = mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockSubSubHeader, "Intro"),
+		mkTestBlock("h2", notionapi.BlockHeader, "Intro 2"),
+		mkTestBlock("h3", notionapi.BlockSubSubHeader, "Intro"),
+		mkTestBlock("h4", notionapi.BlockHeader, "Summary"),
+	)
+	s = renderTestPage(page)
+	exp = `<div class="toc"><ul>` +
+		`<li><a href="#intro">Intro</a></li>` +
+		`<li><a href="#intro-2">Intro 2</a><ul>` +
+		`<li><a href="#intro-3">Intro</a></li>` +
+		`</ul></li>` +
+		`<li><a href="#summary">Summary</a></li>` +
+		`</ul></div>`
+	assert.Contains(t, s, exp)
+	assert.Contains(t, s, `<h3 class="notion-header-3" id="intro-3">`)
+}
+
+func TestRenderImageLocalized(t *testing.T) {
+	nRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png data"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	imgURL := srv.URL + "/image"
+	block := mkTestBlock("i1", notionapi.BlockImage, "")
+	block.Source = imgURL
+	page := mkTestPageWithBlocks(block)
+	render := func() string {
+		r := NewHTMLRenderer(&notionapi.Client{}, page)
+		return string(r.Gen())
+	}
+	name := sha1OfLink(imgURL) + ".png"
+	s := render()
+	assert.Contains(t, s, `src="/img/`+name+`"`)
+	d, err := ioutil.ReadFile(filepath.Join(dir, "img", name))
+	assert.NoError(t, err)
+	assert.Equal(t, "png data", string(d))
+
+	// cached image is not downloaded again
+	s = render()
+	assert.Contains(t, s, `src="/img/`+name+`"`)
+	assert.Equal(t, 1, nRequests)
+
+	// if download fails, we keep the original url
+	missingURL := srv.URL + "/missing.png"
+	block.Source = missingURL
+	s = render()
+	assert.Contains(t, s, `src="`+missingURL+`"`)
+}
+
+func TestRenderCalloutAndQuote(t *testing.T) {
+	callout := mkTestBlock("c1", blockCallout, "")
+	callout.InlineContent = []*notionapi.InlineBlock{
+		{Text: "Read "},
+		{Text: "this", AttrFlags: notionapi.AttrBold},
+	}
+	callout.FormatRaw = []byte(`{"page_icon":"💡","block_color":"gray_background"}`)
+	quote := mkTestBlock("q1", notionapi.BlockQuote, "To be or not to be")
+	page := mkTestPageWithBlocks(callout, quote)
+
+	s := renderTestPage(page)
+	assert.Contains(t, s, `<div class="notion-callout" id="c1">`)
+	assert.Contains(t, s, `<span class="notion-callout-icon">💡</span>`)
+	assert.Contains(t, s, `<b>this</b>`)
+	assert.Contains(t, s, `<blockquote class="notion-quote" id="q1">`)
+	assert.Contains(t, s, "To be or not to be")
+	assert.NotContains(t, s, "<quote")
+	// rendering doesn't change the page
+	assert.Equal(t, blockCallout, callout.Type)
+}
+
+func TestRenderToggle(t *testing.T) {
+	toggle := mkTestBlock("tg", notionapi.BlockToggle, "")
+	toggle.InlineContent = []*notionapi.InlineBlock{
+		{Text: "Show "},
+		{Text: "details", AttrFlags: notionapi.AttrBold},
+	}
+	toggle.Content = []*notionapi.Block{
+		mkTestBlock("t1", notionapi.BlockText, "Hidden paragraph"),
+	}
+	s := renderTestPage(mkTestPageWithBlocks(toggle))
+	exp := `<details class="notion-toggle" id="tg">
+  <summary>
+    Show <b>details</b>
+</summary>
+    <div class="notion-text" id="t1">
+      Hidden paragraph
+    </div>
+</details>`
+	assert.Contains(t, s, exp)
+}
+
+func TestRenderCodeCaption(t *testing.T) {
+	code := mkTestBlock("c1", notionapi.BlockCode, "")
+	code.Code = "package main"
+	code.CodeLanguage = "go"
+	code.Properties = map[string]interface{}{
+		"caption": []interface{}{[]interface{}{"cmd/<main>.go"}},
+	}
+	noCaption := mkTestBlock("c2", notionapi.BlockCode, "")
+	noCaption.Code = "ls -la"
+	s := renderTestPage(mkTestPageWithBlocks(code, noCaption))
+	assert.Contains(t, s, `<figure class="notion-code" data-filename="cmd/&lt;main&gt;.go">`)
+	assert.Contains(t, s, `<figcaption class="notion-code-caption">cmd/&lt;main&gt;.go</figcaption>`)
+	assert.Equal(t, 1, strings.Count(s, "<figure"))
+	assert.Equal(t, 2, strings.Count(s, `<pre class="chroma">`))
+}
+
+func TestCopyCodeButton(t *testing.T) {
+	code := mkTestBlock("c1", notionapi.BlockCode, "")
+	code.Code = "ls -la"
+	codePage := mkTestPageWithBlocks(code)
+	textPage := mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "no code here"))
+
+	s := renderTestPage(codePage)
+	assert.Contains(t, s, `<div class="notion-code"><button class="copy-code" type="button">Copy</button><pre class="chroma">`)
+	s = renderTestPage(textPage)
+	assert.NotContains(t, s, "copy-code")
+
+	article := mkTestArticle("1", "Code", "2019-01-01", statusNormal)
+	article.HasCode = hasCodeBlocks(codePage)
+	assert.True(t, article.HasCode)
+	assert.Contains(t, execTestArticleTemplate(t, article), `<script defer src="/js/copy.js"></script>`)
+	article.HasCode = hasCodeBlocks(textPage)
+	assert.False(t, article.HasCode)
+	assert.NotContains(t, execTestArticleTemplate(t, article), "copy.js")
+	assert.FileExists(t, filepath.Join("www", "js", "copy.js"))
+}
+
+func TestRenderEquation(t *testing.T) {
+	equation := mkTestBlock("e1", blockEquation, `a < b \implies \sqrt{a} < \sqrt{b}`)
+	page := mkTestPageWithBlocks(equation)
+	s := renderTestPage(page)
+	assert.Contains(t, s, `<span class="math math-display">a &lt; b \implies \sqrt{a} &lt; \sqrt{b}</span>`)
+	assert.NotContains(t, s, "<blockquote")
+	assert.Equal(t, blockEquation, equation.Type)
+
+	article := mkTestArticle("1", "Math", "2019-01-01", statusNormal)
+	assert.NotContains(t, execTestArticleTemplate(t, article), "katex")
+	article.page = page
+	article.HasMath = hasEquations(page)
+	assert.True(t, article.HasMath)
+	assert.Contains(t, execTestArticleTemplate(t, article), "katex.min.js")
+}
+
+func TestRenderTable(t *testing.T) {
+	table := mkTestBlock("tb1", notionapi.BlockTable, "")
+	table.FormatRaw = []byte(`{"table_block_column_order":["a","b"],"table_block_column_header":true}`)
+	header := mkTestBlock("r1", blockTableRow, "")
+	header.Properties = map[string]interface{}{
+		"a": []interface{}{[]interface{}{"Name"}},
+		"b": []interface{}{[]interface{}{"Value"}},
+	}
+	row := mkTestBlock("r2", blockTableRow, "")
+	row.Properties = map[string]interface{}{
+		"a": []interface{}{[]interface{}{"size"}},
+		"b": []interface{}{[]interface{}{"42", []interface{}{[]interface{}{"b"}}}},
+	}
+	table.Content = []*notionapi.Block{header, row}
+
+	s := renderTestPage(mkTestPageWithBlocks(table))
+	exp := `<table class="notion-table"><thead><tr><th>Name</th><th>Value</th></tr></thead><tbody><tr><td>size</td><td><b>42</b></td></tr></tbody></table>`
+	assert.Contains(t, s, exp)
+	assert.NotContains(t, s, "<blockquote")
+	assert.Equal(t, notionapi.BlockTable, table.Type)
+
+	// without column header all rows are in tbody
+	table.FormatRaw = []byte(`{"table_block_column_order":["a","b"]}`)
+	s = renderTestPage(mkTestPageWithBlocks(table))
+	assert.NotContains(t, s, "<thead>")
+	assert.Contains(t, s, `<tbody><tr><td>Name</td><td>Value</td></tr><tr>`)
+}
+
+func TestRenderSyncedBlocks(t *testing.T) {
+	src := mkTestBlock("t1", notionapi.BlockText, "Subscribe to the newsletter")
+	ref := mkTestBlock("s1", blockSyncedReference, "")
+	ref.FormatRaw = []byte(`{"transclusion_reference_pointer":{"id":"t1","spaceId":"x"}}`)
+	container := mkTestBlock("s2", blockSyncedContainer, "")
+	container.Content = []*notionapi.Block{mkTestBlock("t2", notionapi.BlockText, "Synced text")}
+	containerRef := mkTestBlock("s3", blockSyncedReference, "")
+	containerRef.FormatRaw = []byte(`{"transclusion_reference_pointer":{"id":"s2"}}`)
+	missing := mkTestBlock("s4", blockSyncedReference, "")
+	missing.FormatRaw = []byte(`{"transclusion_reference_pointer":{"id":"not-in-page"}}`)
+	page := mkTestPageWithBlocks(src, ref, container, containerRef, missing)
+
+	s := renderTestPage(page)
+	assert.Equal(t, 2, strings.Count(s, "Subscribe to the newsletter"))
+	assert.Equal(t, 2, strings.Count(s, "Synced text"))
+	assert.NotContains(t, s, "<blockquote")
+	assert.Equal(t, blockSyncedReference, ref.Type)
+	assert.Empty(t, ref.Content)
+	assert.Empty(t, missing.Content)
+}
+
+func TestRenderSyncedBlockHighlights(t *testing.T) {
+	src := mkTestBlock("t1", notionapi.BlockText, "")
+	src.InlineContent = []*notionapi.InlineBlock{
+		{Text: "red text", Highlight: "red"},
+	}
+	ref := mkTestBlock("s1", blockSyncedReference, "")
+	ref.FormatRaw = []byte(`{"transclusion_reference_pointer":{"id":"t1"}}`)
+	page := mkTestPageWithBlocks(src, ref)
+
+	s := renderTestPage(page)
+	assert.Equal(t, 2, strings.Count(s, `<span style="color:#e03e3e">red text</span>`))
+	assert.NotContains(t, s, highlightStart)
+	assert.NotContains(t, s, highlightEnd)
+	// text is restored after rendering
+	assert.Equal(t, "red text", src.InlineContent[0].Text)
+}
+
+func TestRenderImageDeduplicated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the same logo under different urls
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("logo data"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	render := func(uri string) string {
+		block := mkTestBlock("i1", notionapi.BlockImage, "")
+		block.Source = uri
+		page := mkTestPageWithBlocks(block)
+		r := NewHTMLRenderer(&notionapi.Client{}, page)
+		return string(r.Gen())
+	}
+	name := sha1OfLink(srv.URL+"/page1/logo.png") + ".png"
+	s := render(srv.URL + "/page1/logo.png")
+	assert.Contains(t, s, `src="/img/`+name+`"`)
+	s = render(srv.URL + "/page2/logo.png")
+	assert.Contains(t, s, `src="/img/`+name+`"`)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "img"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+
+	// the mapping is persisted and used in the next build
+	imgFiles, imgHashes = nil, nil
+	srv.Close()
+	s = render(srv.URL + "/page2/logo.png")
+	assert.Contains(t, s, `src="/img/`+name+`"`)
+}
+
+func TestNestedLists(t *testing.T) {
+	first := mkTestBlock("b1", notionapi.BlockBulletedList, "first")
+	first.Content = []*notionapi.Block{
+		mkTestBlock("n1", notionapi.BlockNumberedList, "one"),
+		mkTestBlock("n2", notionapi.BlockNumberedList, "two"),
+	}
+	second := mkTestBlock("b2", notionapi.BlockBulletedList, "second")
+	second.Content = []*notionapi.Block{
+		mkTestBlock("b2a", notionapi.BlockBulletedList, "nested"),
+	}
+	page := mkTestPageWithBlocks(
+		first,
+		second,
+		mkTestBlock("n3", notionapi.BlockNumberedList, "three"),
+	)
+	// compare without formatting whitespace
+	got := strings.Join(strings.Fields(renderTestPage(page)), "")
+	exp := `<ulclass="notion-bulleted-list">` +
+		`<liclass="notion-bulleted-list"id="b1">first` +
+		`<olclass="notion-numbered-list">` +
+		`<liclass="notion-numbered-list"id="n1">one</li>` +
+		`<liclass="notion-numbered-list"id="n2">two</li>` +
+		`</ol></li>` +
+		`<liclass="notion-bulleted-list"id="b2">second` +
+		`<ulclass="notion-bulleted-list">` +
+		`<liclass="notion-bulleted-list"id="b2a">nested</li>` +
+		`</ul></li></ul>` +
+		`<olclass="notion-numbered-list">` +
+		`<liclass="notion-numbered-list"id="n3">three</li>` +
+		`</ol>`
+	assert.Contains(t, got, exp)
+}
+
+func TestRenderDivider(t *testing.T) {
+	page := mkTestPageWithBlocks(
+		mkTestBlock("t1", notionapi.BlockText, "before"),
+		mkTestBlock("d1", notionapi.BlockDivider, ""),
+		mkTestBlock("t2", notionapi.BlockText, "after"),
+	)
+	s := renderTestPage(page)
+	before := strings.Index(s, "before")
+	hr := strings.Index(s, `<hr class="notion-divider">`)
+	after := strings.Index(s, "after")
+	assert.True(t, before >= 0 && hr >= 0 && after >= 0)
+	assert.True(t, before < hr && hr < after)
+}
+
+func TestRenderHighlights(t *testing.T) {
+	block := mkTestBlock("t1", notionapi.BlockText, "")
+	block.InlineContent = []*notionapi.InlineBlock{
+		{Text: "plain "},
+		{Text: "red <text>", Highlight: "red"},
+		{Text: " and "},
+		{Text: "marked", Highlight: "yellow_background", AttrFlags: notionapi.AttrBold},
+		{Text: " unknown", Highlight: "rainbow"},
+	}
+	page := mkTestPageWithBlocks(block)
+	s := renderTestPage(page)
+	exp := `plain <span style="color:#e03e3e">red &lt;text&gt;</span> and ` +
+		`<b><span style="background-color:#fbf3db">marked</span></b> unknown`
+	assert.Contains(t, s, exp)
+	// text is restored after rendering
+	assert.Equal(t, "red <text>", block.InlineContent[1].Text)
+}
+
+func TestRenderImageCaption(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	link := srv.URL + "/img.png"
+	block := mkTestBlock("i1", notionapi.BlockImage, "")
+	block.Source = link
+	block.Properties = map[string]interface{}{
+		"caption": []interface{}{
+			[]interface{}{"A "},
+			[]interface{}{"bold", []interface{}{[]interface{}{"b"}}},
+			[]interface{}{" <caption>"},
+		},
+	}
+	plain := mkTestBlock("i2", notionapi.BlockImage, "")
+	plain.Source = link
+	page := mkTestPageWithBlocks(block, plain)
+
+	// image download fails so the original url is used
+	r := NewHTMLRenderer(&notionapi.Client{}, page)
+	s := string(r.Gen())
+	got := strings.Join(strings.Fields(s), " ")
+	exp := `<figure class="notion-image"> <img class="blog-img" src="` + link + `" alt="A bold &lt;caption&gt;" id="i1">`
+	assert.Contains(t, got, exp)
+	assert.Contains(t, got, `<figcaption>A <b>bold</b> &lt;caption&gt;</figcaption> </figure>`)
+	// images without caption are not wrapped
+	assert.Equal(t, 1, strings.Count(s, "<figure"))
+	assert.Contains(t, s, `<img class="blog-img" src="`+link+`" alt="img" id="i2">`)
+}
+
+func TestImageAlt(t *testing.T) {
+	prevStrictAlt := flgStrictAlt
+	defer func() {
+		flgStrictAlt = prevStrictAlt
+	}()
+
+	assert.Equal(t, "go logo", imageFileName("https://example.com/img/go%20logo.png?width=200"))
+	assert.Equal(t, "", imageFileName("https://example.com/"))
+	s3URL := "https://s3-us-west-2.amazonaws.com/secure.notion-static.com/5d4c/My%20diagram.png?X-Amz-Date=20190401T000000Z"
+	assert.Equal(t, "My diagram", imageFileName("https://www.notion.so/image/"+url.QueryEscape(s3URL)+"?width=200"))
+	assert.Equal(t, "", imageFileName("https://www.notion.so/image/"+url.QueryEscape("https://s3.amazonaws.com/x/image.png")))
+	assert.Equal(t, "", imageFileName("https://example.com/img/Untitled.png"))
+
+	captioned := mkTestBlock("i1", notionapi.BlockImage, "")
+	captioned.Source = "https://example.com/"
+	captioned.Properties = map[string]interface{}{
+		"caption": []interface{}{[]interface{}{"Gopher & friends"}},
+	}
+	missing := mkTestBlock("i2", notionapi.BlockImage, "")
+	missing.Source = "https://example.com/"
+	r := NewHTMLRenderer(nil, mkTestPageWithBlocks(captioned, missing))
+	assert.Equal(t, "Gopher &amp; friends", r.imageAlt(captioned))
+	assert.Equal(t, "", r.imageAlt(missing))
+
+	flgStrictAlt = true
+	assert.Equal(t, "Gopher &amp; friends", r.imageAlt(captioned))
+	msg := recoverPanicMsg(func() {
+		r.imageAlt(missing)
+	})
+	assert.Contains(t, msg, "image i2 in page https://notion.so/"+mkTestPageID(1)+" has no alt text")
+}
+
+func TestRenderImagesConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	nRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		nRequests++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png data"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blog_images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCacheDir := cacheDir
+	cacheDir = dir
+	imgFiles, imgHashes = nil, nil
+	defer func() {
+		cacheDir = prevCacheDir
+		imgFiles, imgHashes = nil, nil
+	}()
+
+	// all pages use the same image
+	imgURL := srv.URL + "/image"
+	var pages []*notionapi.Page
+	for i := 0; i < 16; i++ {
+		block := mkTestBlock("i1", notionapi.BlockImage, "")
+		block.Source = imgURL
+		pages = append(pages, mkTestPageWithBlocks(block, mkTestBlock("t1", notionapi.BlockText, "text")))
+	}
+	res := make([]string, len(pages))
+	forEachConcurrently(len(pages), 8, func(i int) {
+		html, _ := notionToHTML(&notionapi.Client{}, pages[i], nil)
+		res[i] = string(html)
+	})
+	name := sha1OfLink(imgURL) + ".png"
+	for _, s := range res {
+		assert.Contains(t, s, `src="/img/`+name+`"`)
+		assert.Contains(t, s, "text")
+	}
+	assert.Equal(t, 1, nRequests)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kjk/notionapi"
+)
+
+// MarkdownGenerator converts notion page of an article to CommonMark
+type MarkdownGenerator struct {
+	article  *Article
+	articles *Articles
+	buf      bytes.Buffer
+}
+
+// yamlString returns s as double-quoted yaml string. json escaping
+// is a subset of yaml escaping
+func yamlString(s string) string {
+	d, _ := json.Marshal(s)
+	return string(d)
+}
+
+func (g *MarkdownGenerator) writeFrontmatter() {
+	a := g.article
+	g.buf.WriteString("---\n")
+	add := func(key, val string) {
+		if val != "" {
+			fmt.Fprintf(&g.buf, "%s: %s\n", key, yamlString(val))
+		}
+	}
+	add("title", a.Title)
+	add("id", a.ID)
+	add("date", a.PublishedOn.Format("2006-01-02"))
+	if !a.UpdatedOn.IsZero() {
+		add("updated", a.UpdatedOn.Format("2006-01-02"))
+	}
+	if len(a.Tags) > 0 {
+		g.buf.WriteString("tags:\n")
+		for _, tag := range a.Tags {
+			fmt.Fprintf(&g.buf, "  - %s\n", yamlString(tag))
+		}
+	}
+	add("status", statusName(a.Status))
+	add("description", a.Description)
+	add("author", a.Author)
+	add("collection", a.Collection)
+	add("header_image", a.HeaderImageURL)
+	g.buf.WriteString("---\n\n")
+}
+
+// escapes characters that have a special meaning in markdown text
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+
+func inlinesToMarkdown(blocks []*notionapi.InlineBlock) string {
+	var s string
+	for _, b := range blocks {
+		text := b.Text
+		if b.AttrFlags&notionapi.AttrCode != 0 {
+			text = "`" + text + "`"
+		} else {
+			text = markdownEscaper.Replace(text)
+		}
+		if b.AttrFlags&notionapi.AttrBold != 0 {
+			text = "**" + text + "**"
+		}
+		if b.AttrFlags&notionapi.AttrItalic != 0 {
+			text = "*" + text + "*"
+		}
+		if b.AttrFlags&notionapi.AttrStrikeThrought != 0 {
+			text = "~~" + text + "~~"
+		}
+		if b.Link != "" {
+			text = "[" + text + "](" + b.Link + ")"
+		}
+		s += text
+	}
+	return s
+}
+
+// returns url and title of a sub-page
+func (g *MarkdownGenerator) pageLink(block *notionapi.Block) (string, string) {
+	id := normalizeID(block.ID)
+	if g.articles != nil {
+		if article := g.articles.idToArticle[id]; article != nil {
+			return article.URL(), article.Title
+		}
+	}
+	return "https://www.notion.so/" + id, block.Title
+}
+
+func isMarkdownListItem(block *notionapi.Block) bool {
+	switch block.Type {
+	case notionapi.BlockBulletedList, notionapi.BlockNumberedList, notionapi.BlockTodo:
+		return true
+	}
+	return false
+}
+
+// writeLines writes s prefixed with indent, first line prefixed with
+// firstPrefix and the rest with restPrefix
+func (g *MarkdownGenerator) writeLines(indent, firstPrefix, restPrefix, s string) {
+	for i, line := range strings.Split(s, "\n") {
+		prefix := restPrefix
+		if i == 0 {
+			prefix = firstPrefix
+		}
+		g.buf.WriteString(strings.TrimRight(indent+prefix+line, " ") + "\n")
+	}
+}
+
+func (g *MarkdownGenerator) genBlocks(blocks []*notionapi.Block, indent string) {
+	for i, block := range blocks {
+		if block == nil {
+			continue
+		}
+		if i > 0 {
+			prev := blocks[i-1]
+			// items of the same list are not separated by empty line
+			if prev == nil || !isMarkdownListItem(prev) || prev.Type != block.Type {
+				g.buf.WriteString("\n")
+			}
+		}
+		g.genBlock(block, indent)
+	}
+}
+
+func (g *MarkdownGenerator) genBlock(block *notionapi.Block, indent string) {
+	text := inlinesToMarkdown(block.InlineContent)
+	// indentation of children of list items
+	childIndent := indent
+	switch block.Type {
+	case notionapi.BlockText:
+		g.writeLines(indent, "", "", text)
+	case notionapi.BlockHeader:
+		g.writeLines(indent, "# ", "", text)
+	case notionapi.BlockSubHeader:
+		g.writeLines(indent, "## ", "", text)
+	case notionapi.BlockSubSubHeader:
+		g.writeLines(indent, "### ", "", text)
+	case notionapi.BlockBulletedList, notionapi.BlockToggle:
+		g.writeLines(indent, "- ", "  ", text)
+		childIndent = indent + "  "
+	case notionapi.BlockNumberedList:
+		g.writeLines(indent, "1. ", "   ", text)
+		childIndent = indent + "   "
+	case notionapi.BlockTodo:
+		check := "[ ] "
+		if block.IsChecked {
+			check = "[x] "
+		}
+		g.writeLines(indent, "- "+check, "  ", text)
+		childIndent = indent + "  "
+	case notionapi.BlockQuote, blockCallout:
+		if icon := calloutIcon(block); icon != "" && !strings.HasPrefix(icon, "http") {
+			text = icon + " " + text
+		}
+		g.writeLines(indent, "> ", "> ", text)
+	case notionapi.BlockCode:
+		g.writeLines(indent, "", "", "```"+block.CodeLanguage+"\n"+strings.TrimRight(block.Code, "\n")+"\n```")
+	case blockEquation:
+		g.writeLines(indent, "", "", "$$\n"+inlinesToText(block.InlineContent)+"\n$$")
+	case notionapi.BlockDivider:
+		g.writeLines(indent, "", "", "---")
+	case notionapi.BlockImage:
+		g.writeLines(indent, "", "", "![]("+block.Source+")")
+	case notionapi.BlockPage:
+		uri, title := g.pageLink(block)
+		g.writeLines(indent, "", "", "["+markdownEscaper.Replace(title)+"]("+uri+")")
+		// content of sub-pages is exported separately
+		return
+	case notionapi.BlockBookmark:
+		info := bookmarkInfoFromBlock(block)
+		title := info.Title
+		if title == "" {
+			title = block.Link
+		}
+		g.writeLines(indent, "", "", "["+markdownEscaper.Replace(title)+"]("+block.Link+")")
+	default:
+		// embeds, videos, files etc.
+		uri := block.Source
+		if uri == "" {
+			uri = block.Link
+		}
+		if uri != "" {
+			g.writeLines(indent, "", "", "<"+uri+">")
+		} else if text != "" {
+			g.writeLines(indent, "", "", text)
+		}
+	}
+	if len(block.Content) > 0 {
+		g.buf.WriteString("\n")
+		g.genBlocks(block.Content, childIndent)
+	}
+}
+
+// genMarkdown returns content of the article as markdown with metadata
+// in yaml frontmatter. articles are used to resolve links to sub-pages
+// and can be nil
+func genMarkdown(article *Article, articles *Articles) []byte {
+	g := &MarkdownGenerator{
+		article:  article,
+		articles: articles,
+	}
+	g.writeFrontmatter()
+	if article.page != nil && article.page.Root != nil {
+		g.genBlocks(article.page.Root.Content, "")
+	}
+	return g.buf.Bytes()
+}
+
+func netlifyArticleMarkdownPath(article *Article) string {
+	return "/article/" + article.Slug() + "/index.md"
+}
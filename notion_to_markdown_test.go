@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenMarkdown(t *testing.T) {
+	bold := mkTestBlock("b1", notionapi.BlockText, "")
+	bold.InlineContent = []*notionapi.InlineBlock{
+		{Text: "Some "},
+		{Text: "bold", AttrFlags: notionapi.AttrBold},
+		{Text: " and "},
+		{Text: "a link", Link: "https://example.com"},
+		{Text: " with 2*3"},
+	}
+	code := mkTestBlock("b5", notionapi.BlockCode, "")
+	code.Code = "fmt.Println(\"hi\")\n"
+	code.CodeLanguage = "go"
+	nested := mkTestBlock("b3", notionapi.BlockBulletedList, "second")
+	nested.Content = []*notionapi.Block{
+		mkTestBlock("b3a", notionapi.BlockBulletedList, "nested"),
+	}
+	image := mkTestBlock("b8", notionapi.BlockImage, "")
+	image.Source = "https://example.com/img.png"
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b0", notionapi.BlockHeader, "Intro"),
+		bold,
+		mkTestBlock("b2", notionapi.BlockBulletedList, "first"),
+		nested,
+		mkTestBlock("b4", notionapi.BlockNumberedList, "one"),
+		code,
+		mkTestBlock("b6", notionapi.BlockQuote, "quoted"),
+		mkTestBlock("b7", notionapi.BlockDivider, ""),
+		image,
+	)
+	article := mkTestArticle(page.ID, "Hello: world", "2019-04-10", statusNormal)
+	article.Tags = []string{"go", "notion"}
+	article.page = page
+
+	got := string(genMarkdown(article, nil))
+	exp := `---
+title: "Hello: world"
+id: "` + page.ID + `"
+date: "2019-04-10"
+updated: "2019-04-10"
+tags:
+  - "go"
+  - "notion"
+status: "normal"
+---
+
+# Intro
+
+Some **bold** and [a link](https://example.com) with 2\*3
+
+- first
+- second
+
+  - nested
+
+1. one
+
+` + "```go\nfmt.Println(\"hi\")\n```" + `
+
+> quoted
+
+---
+
+![](https://example.com/img.png)
+`
+	assert.Equal(t, exp, got)
+	assert.Equal(t, "/article/"+article.Slug()+"/index.md", netlifyArticleMarkdownPath(article))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/kjk/notionapi"
+)
+
+// loadArticlesOnly is a fast version of loadArticles for -only. Only pageID
+// is downloaded (unless cached and useCacheForNotion is set). Other pages
+// come from cache without checking if they changed
+func loadArticlesOnly(c *notionapi.Client, pageID string) *Articles {
+	cachedPages := loadPagesFromDisk(cacheDir)
+	loadPage := func(id string, n int) (*notionapi.Page, error) {
+		if id != pageID {
+			if page := cachedPages[id]; page != nil {
+				return page, nil
+			}
+		}
+		if useCacheForNotion {
+			if page := loadPageFromCache(cacheDir, id); page != nil {
+				return page, nil
+			}
+		}
+		page, err := downloadAndCachePage(c, id)
+		if err == nil {
+			lg("Downloaded %s %s\n", id, page.Root.Title)
+		}
+		return page, err
+	}
+
+	idToPage := map[string]*notionapi.Page{}
+	idToParentID := map[string]string{}
+	err := crawlNotionPages(rootPageIDs, idToPage, idToParentID, flgConcurrency, loadPage)
+	panicIfErr(err)
+
+	var idToRow map[string]*collectionRow
+	if collectionPageID != "" {
+		idToRow = loadCollectionPages(collectionPageID, idToPage, idToParentID, loadPage)
+	}
+	panicIf(idToPage[pageID] == nil, "page %s is not part of the website", pageID)
+	return articlesFromPages(c, idToPage, idToParentID, idToRow)
+}
+
+// rebuildOnly re-generates html of a single page and the index page
+func rebuildOnly(c *notionapi.Client, pageID string) {
+	loadTemplates()
+	store := loadArticlesOnly(c, pageID)
+	article := store.idToArticle[pageID]
+	panicIf(article == nil, "failed to convert page %s to article", pageID)
+	path := netlifyArticlePath(article)
+	netlifyWriteArticle(article, path, false)
+	lg("Wrote %s (%s)\n", netlifyPath(path), article.Title)
+	err := genIndex(store, nil)
+	panicIfErr(err)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebuildOnly(t *testing.T) {
+	defer setTestOutDir(t)()
+	dir, err := ioutil.TempDir("", "blog_cache")
+	assert.NoError(t, err)
+	prevCacheDir, prevRoots, prevUseCache, prevCollection := cacheDir, rootPageIDs, useCacheForNotion, collectionPageID
+	cacheDir, rootPageIDs, useCacheForNotion, collectionPageID = dir, []string{mkTestPageID(1)}, true, ""
+	defer func() {
+		cacheDir, rootPageIDs, useCacheForNotion, collectionPageID = prevCacheDir, prevRoots, prevUseCache, prevCollection
+		imgFiles, imgHashes = nil, nil
+		os.RemoveAll(dir)
+	}()
+
+	pages := []*notionapi.Page{
+		mkTestPage(mkTestPageID(1), mkTestPageID(2), mkTestPageID(3)),
+		mkTestPage(mkTestPageID(2)),
+		mkTestPage(mkTestPageID(3)),
+		// in cache but no longer part of the website
+		mkTestPage(mkTestPageID(4)),
+	}
+	for _, page := range pages {
+		d, err := json.Marshal(page)
+		assert.NoError(t, err)
+		err = ioutil.WriteFile(filepath.Join(dir, page.ID+".json"), d, 0644)
+		assert.NoError(t, err)
+	}
+
+	store := loadArticlesOnly(nil, mkTestPageID(2))
+	assert.Equal(t, 3, len(store.articles))
+	assert.Equal(t, mkTestPageID(1), store.idToParentID[mkTestPageID(3)])
+
+	rebuildOnly(nil, mkTestPageID(2))
+	assert.FileExists(t, netlifyPath(netlifyArticlePath(store.idToArticle[mkTestPageID(2)])))
+	assert.FileExists(t, netlifyPath("/index.html"))
+	// other articles are not written
+	files, err := ioutil.ReadDir(filepath.Join(flgOutDir, "article"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+
+	msg := recoverPanicMsg(func() {
+		loadArticlesOnly(nil, mkTestPageID(4))
+	})
+	assert.Contains(t, msg, "is not part of the website")
+}
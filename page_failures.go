@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// pageFailure is a page that failed to download or process, recorded
+// instead of aborting the build when -keep-going is set
+type pageFailure struct {
+	pageID string
+	err    error
+}
+
+var (
+	pageFailuresMu sync.Mutex
+	pageFailures   []pageFailure
+)
+
+func addPageFailure(pageID string, err error) {
+	logError(err, "page %s failed", pageID)
+	pageFailuresMu.Lock()
+	pageFailures = append(pageFailures, pageFailure{pageID: pageID, err: err})
+	pageFailuresMu.Unlock()
+}
+
+// processPage calls fn which processes a page. Without -keep-going it
+// doesn't do anything special. With -keep-going, if fn panics, the page
+// is recorded as failed and we return false
+func processPage(pageID string, fn func()) (ok bool) {
+	if !flgKeepGoing {
+		fn()
+		return true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err, isErr := r.(error)
+			if !isErr {
+				err = fmt.Errorf("%v", r)
+			}
+			addPageFailure(normalizeID(pageID), err)
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+// reportPageFailures logs all failed pages and returns their number
+func reportPageFailures() int {
+	pageFailuresMu.Lock()
+	defer pageFailuresMu.Unlock()
+	if len(pageFailures) == 0 {
+		return 0
+	}
+	logWarn("%d pages failed:\n", len(pageFailures))
+	for _, f := range pageFailures {
+		logWarn("  https://notion.so/%s: %s\n", f.pageID, f.err)
+	}
+	return len(pageFailures)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kjk/notionapi"
+)
+
+// calls cb for every block in blocks and their children, skipping
+// the content of sub-pages (which are only linked from a page)
+func forEachPageBlock(blocks []*notionapi.Block, cb func(*notionapi.Block)) {
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		cb(block)
+		if block.Type != notionapi.BlockPage {
+			forEachPageBlock(block.Content, cb)
+		}
+	}
+}
+
+// countWords returns number of words in text of a page
+func countWords(page *notionapi.Page) int {
+	if page == nil || page.Root == nil {
+		return 0
+	}
+	n := 0
+	forEachPageBlock(page.Root.Content, func(block *notionapi.Block) {
+		if block.Type == notionapi.BlockPage {
+			return
+		}
+		for _, inline := range block.InlineContent {
+			n += len(strings.Fields(inline.Text))
+		}
+	})
+	return n
+}
+
+// pageText returns text of a page without formatting, with whitespace
+// collapsed to single spaces
+func pageText(page *notionapi.Page) string {
+	if page == nil || page.Root == nil {
+		return ""
+	}
+	var parts []string
+	forEachPageBlock(page.Root.Content, func(block *notionapi.Block) {
+		switch block.Type {
+		case notionapi.BlockPage:
+			return
+		case notionapi.BlockCode:
+			parts = append(parts, block.Code)
+			return
+		}
+		// text, headers, lists, todo, toggle, quote, callout etc.
+		parts = append(parts, inlinesToText(block.InlineContent))
+	})
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// countBlocks returns number of blocks in the page for which isMatch returns true
+func countBlocks(page *notionapi.Page, isMatch func(*notionapi.Block) bool) int {
+	if page == nil || page.Root == nil {
+		return 0
+	}
+	n := 0
+	forEachPageBlock(page.Root.Content, func(block *notionapi.Block) {
+		if isMatch(block) {
+			n++
+		}
+	})
+	return n
+}
+
+// countHeaders returns number of headers of all levels in the page
+func countHeaders(page *notionapi.Page) int {
+	return countBlocks(page, func(block *notionapi.Block) bool {
+		switch block.Type {
+		case notionapi.BlockHeader, notionapi.BlockSubHeader, notionapi.BlockSubSubHeader:
+			return true
+		}
+		return false
+	})
+}
+
+// countImages returns number of images in the page
+func countImages(page *notionapi.Page) int {
+	return countBlocks(page, func(block *notionapi.Block) bool {
+		return block.Type == notionapi.BlockImage
+	})
+}
+
+// hasEquations returns true if the page has equation blocks
+func hasEquations(page *notionapi.Page) bool {
+	return countBlocks(page, func(block *notionapi.Block) bool {
+		return block.Type == blockEquation
+	}) > 0
+}
+
+// hasCodeBlocks returns true if the page has code blocks
+func hasCodeBlocks(page *notionapi.Page) bool {
+	return countBlocks(page, func(block *notionapi.Block) bool {
+		return block.Type == notionapi.BlockCode
+	}) > 0
+}
+
+// pageSummary returns text of the first paragraph of the page, truncated
+// to at most maxLen bytes at word boundary
+func pageSummary(page *notionapi.Page, maxLen int) string {
+	if page == nil || page.Root == nil {
+		return ""
+	}
+	s := ""
+	for _, block := range page.Root.Content {
+		if block == nil || block.Type != notionapi.BlockText {
+			continue
+		}
+		s = strings.TrimSpace(inlinesToText(block.InlineContent))
+		if s != "" {
+			break
+		}
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= maxLen {
+		return s
+	}
+	// don't cut utf-8 sequence of a character in half
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	s = s[:maxLen]
+	if idx := strings.LastIndex(s, " "); idx > 0 {
+		s = s[:idx]
+	}
+	return strings.TrimRight(s, ",.;:- ") + "…"
+}
+
+// estimateReadingTime returns reading time rounded up to a full minute
+func estimateReadingTime(page *notionapi.Page) time.Duration {
+	nWords := countWords(page)
+	if nWords == 0 {
+		return 0
+	}
+	wpm := flgWordsPerMinute
+	if wpm <= 0 {
+		wpm = 200
+	}
+	minutes := (nWords + wpm - 1) / wpm
+	return time.Duration(minutes) * time.Minute
+}
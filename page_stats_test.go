@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateReadingTime(t *testing.T) {
+	prevWPM := flgWordsPerMinute
+	defer func() {
+		flgWordsPerMinute = prevWPM
+	}()
+	flgWordsPerMinute = 200
+
+	assert.Equal(t, time.Duration(0), estimateReadingTime(nil))
+	page := mkTestPageWithBlocks()
+	assert.Equal(t, time.Duration(0), estimateReadingTime(page))
+
+	words := func(n int) string {
+		return strings.TrimSpace(strings.Repeat("word ", n))
+	}
+	list := mkTestBlock("l1", notionapi.BlockBulletedList, words(100))
+	list.Content = []*notionapi.Block{
+		mkTestBlock("l2", notionapi.BlockBulletedList, words(50)),
+	}
+	// words in sub-pages are not counted
+	subPage := mkTestBlock("p1", notionapi.BlockPage, words(1000))
+	subPage.Content = []*notionapi.Block{
+		mkTestBlock("t3", notionapi.BlockText, words(1000)),
+	}
+	page = mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, words(2)),
+		mkTestBlock("t1", notionapi.BlockText, words(300)),
+		mkTestBlock("t2", notionapi.BlockText, words(50)),
+		list,
+		subPage,
+	)
+	assert.Equal(t, 502, countWords(page))
+	assert.Equal(t, 3*time.Minute, estimateReadingTime(page))
+
+	flgWordsPerMinute = 502
+	assert.Equal(t, time.Minute, estimateReadingTime(page))
+}
+
+func TestCountHeadersAndImages(t *testing.T) {
+	assert.Equal(t, 0, countHeaders(nil))
+	assert.Equal(t, 0, countImages(nil))
+
+	toggle := mkTestBlock("g1", notionapi.BlockToggle, "toggle")
+	toggle.Content = []*notionapi.Block{
+		mkTestBlock("h4", notionapi.BlockSubSubHeader, "nested header"),
+		mkTestBlock("i2", notionapi.BlockImage, ""),
+	}
+	// headers and images in sub-pages are not counted
+	subPage := mkTestBlock("p1", notionapi.BlockPage, "sub page")
+	subPage.Content = []*notionapi.Block{
+		mkTestBlock("h5", notionapi.BlockHeader, "header"),
+		mkTestBlock("i3", notionapi.BlockImage, ""),
+	}
+	page := mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "header"),
+		mkTestBlock("h2", notionapi.BlockSubHeader, "sub header"),
+		mkTestBlock("t1", notionapi.BlockText, "text"),
+		mkTestBlock("i1", notionapi.BlockImage, ""),
+		mkTestBlock("h3", notionapi.BlockSubSubHeader, "sub sub header"),
+		toggle,
+		subPage,
+	)
+	assert.Equal(t, 4, countHeaders(page))
+	assert.Equal(t, 2, countImages(page))
+}
+
+func TestPageSummary(t *testing.T) {
+	assert.Equal(t, "", pageSummary(nil, 10))
+	page := mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "header"),
+		mkTestBlock("t1", notionapi.BlockText, "  Short  text. "),
+	)
+	assert.Equal(t, "Short text.", pageSummary(page, 160))
+	page = mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "Some longer text, to truncate"))
+	assert.Equal(t, "Some longer…", pageSummary(page, 14))
+
+	// truncating doesn't split multi-byte characters
+	page = mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "zażółć gęślą jaźń"))
+	for maxLen := 1; maxLen < 24; maxLen++ {
+		s := pageSummary(page, maxLen)
+		assert.True(t, utf8.ValidString(s), "maxLen: %d, s: %q", maxLen, s)
+	}
+	assert.Equal(t, "zażółć…", pageSummary(page, 14))
+	page = mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, "żółw"))
+	assert.Equal(t, "ż…", pageSummary(page, 3))
+}
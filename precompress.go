@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// smaller files are not worth compressing
+	precompressMinSize = 1024
+	// skip files that compress to more than this % of original size
+	precompressMaxRatio = 90
+)
+
+var precompressExts = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".json": true,
+}
+
+// precompressFile writes path.gz if path is big enough and compresses well.
+// Returns true if .gz file was written
+func precompressFile(path string) (bool, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(d) < precompressMinSize {
+		return false, nil
+	}
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w.Write(d)
+	err = w.Close()
+	if err != nil {
+		return false, err
+	}
+	if buf.Len()*100 > len(d)*precompressMaxRatio {
+		return false, nil
+	}
+	_, err = writeFileIfChanged(path+".gz", buf.Bytes())
+	return err == nil, err
+}
+
+// precompressDir writes .gz versions of html, css, js and json files in dir
+// for servers that can serve precompressed files. Returns number of .gz files
+func precompressDir(dir string) (int, error) {
+	n := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !precompressExts[filepath.Ext(path)] {
+			return nil
+		}
+		written, err := precompressFile(path)
+		if written {
+			n++
+		}
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecompressDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_precompress")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	large := "<html><body>" + strings.Repeat("<p>Some text in a paragraph</p>\n", 200) + "</body></html>"
+	random := make([]byte, 4096)
+	rand.Read(random)
+	files := map[string][]byte{
+		"article/foo/index.html": []byte(large),
+		"tiny.html":              []byte("<html></html>"),
+		"random.json":            random,
+		"img/foo.png":            []byte(large),
+	}
+	for name, d := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, d, 0644))
+	}
+
+	n, err := precompressDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	f, err := os.Open(filepath.Join(dir, "article", "foo", "index.html.gz"))
+	assert.NoError(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(d))
+
+	// too small, doesn't compress well or not a text file
+	for _, name := range []string{"tiny.html", "random.json", "img/foo.png"} {
+		assert.False(t, fileExists(filepath.Join(dir, filepath.FromSlash(name)+".gz")), name)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kjk/u"
+)
+
+// preflightCheck verifies that files needed to build the website exist,
+// so that we fail with a clear message before doing any work instead
+// of a panic in the middle of the build
+func preflightCheck() error {
+	var missing []string
+	addMissing := func(path string, what string) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		missing = append(missing, fmt.Sprintf("  %s (%s)", path, what))
+	}
+
+	if !u.DirExists("www") {
+		addMissing("www", "directory with static files and templates")
+	}
+	css := filepath.Join(wwwCSSDir, "main.css")
+	if !u.FileExists(css) {
+		addMissing(css, "css for all pages")
+	}
+	for _, name := range templateNames {
+		if lookupTemplate(name) == "" {
+			addMissing(filepath.Join("www", name), fmt.Sprintf("template, can also be in one of %s", strings.Join(tmplDirs, ", ")))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("can't build the website because those files are missing (did you run it from the root of the repository?):\n%s", strings.Join(missing, "\n"))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightCheck(t *testing.T) {
+	assert.NoError(t, preflightCheck())
+
+	dir, err := ioutil.TempDir("", "blog_css")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevCSSDir := wwwCSSDir
+	wwwCSSDir = dir
+	defer func() {
+		wwwCSSDir = prevCSSDir
+	}()
+
+	err = preflightCheck()
+	assert.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, "those files are missing")
+	assert.Contains(t, msg, filepath.Join(dir, "main.css")+" (css for all pages)")
+	assert.NotContains(t, msg, "template")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// files written during this build, see markGenerated
+var (
+	generatedFiles   = map[string]bool{}
+	generatedFilesMu sync.Mutex
+)
+
+func generatedFileKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// markGenerated records that path is an output of this build, even if
+// it wasn't written because it didn't change. Files in output directory
+// that were not marked are removed by -prune
+func markGenerated(path string) {
+	generatedFilesMu.Lock()
+	generatedFiles[generatedFileKey(path)] = true
+	generatedFilesMu.Unlock()
+}
+
+func isGenerated(path string) bool {
+	generatedFilesMu.Lock()
+	defer generatedFilesMu.Unlock()
+	return generatedFiles[generatedFileKey(path)]
+}
+
+// findOrphanFiles returns files in dir that were not generated by this build,
+// e.g. html files of deleted or renamed articles. Compressed versions of
+// generated files are not orphans, -precompress updates them
+func findOrphanFiles(dir string) ([]string, error) {
+	var res []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if isGenerated(path) {
+			return nil
+		}
+		if strings.HasSuffix(path, ".gz") && isGenerated(strings.TrimSuffix(path, ".gz")) {
+			return nil
+		}
+		res = append(res, path)
+		return nil
+	})
+	return res, err
+}
+
+// pruneOutDir removes orphan files in dir and directories left empty
+// after that. Returns number of removed files
+func pruneOutDir(dir string) (int, error) {
+	orphans, err := findOrphanFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	dirs := map[string]bool{}
+	for _, path := range orphans {
+		verbose("pruning %s\n", path)
+		err = os.Remove(path)
+		if err != nil {
+			return 0, err
+		}
+		for d := filepath.Dir(path); d != filepath.Clean(dir) && d != "."; d = filepath.Dir(d) {
+			dirs[d] = true
+		}
+	}
+	// the deepest directories first so that parents become empty
+	var sorted []string
+	for d := range dirs {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, d := range sorted {
+		// fails if the directory is not empty, which is fine
+		os.Remove(d)
+	}
+	return len(orphans), nil
+}
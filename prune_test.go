@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneOutDir(t *testing.T) {
+	defer setTestOutDir(t)()
+	write := func(path string, s string) {
+		path = filepath.Join(flgOutDir, filepath.FromSlash(path))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(s), 0644))
+	}
+
+	// left by previous build
+	write("article/deleted-1/index.html", "deleted")
+	write("article/deleted-1/index.html.gz", "deleted")
+	write("article/kept-2/index.html.gz", "kept")
+	write("old.html", "old")
+	write("ping", "pong")
+
+	// this build
+	netlifyWriteFile("/ping", []byte("pong"))
+	netlifyWriteFile("/article/kept-2/index.html", []byte("kept"))
+	src := filepath.Join(flgOutDir, "..", filepath.Base(flgOutDir)+"_main.css")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("body{}"), 0644))
+	defer os.Remove(src)
+	assert.NoError(t, copyFile(filepath.Join(flgOutDir, "css", "main.css"), src))
+
+	orphans, err := findOrphanFiles(flgOutDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(orphans))
+
+	n, err := pruneOutDir(flgOutDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	for _, path := range []string{"old.html", "article/deleted-1"} {
+		_, err = os.Stat(filepath.Join(flgOutDir, filepath.FromSlash(path)))
+		assert.True(t, os.IsNotExist(err), "%s not removed", path)
+	}
+	for _, path := range []string{"ping", "article/kept-2/index.html", "article/kept-2/index.html.gz", "css/main.css"} {
+		assert.FileExists(t, filepath.Join(flgOutDir, filepath.FromSlash(path)))
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cssUsage is what generated html files use
+type cssUsage struct {
+	// all words in html files, including text and javascript. Classes
+	// and ids can be added by javascript so we don't only look at
+	// class and id attributes
+	words map[string]bool
+	// names of html elements
+	tags map[string]bool
+}
+
+var (
+	htmlWordRx     = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_-]*`)
+	htmlTagRx      = regexp.MustCompile(`<([A-Za-z][A-Za-z0-9-]*)`)
+	cssCommentRx   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssClassOrIDRx = regexp.MustCompile(`[.#](-?[A-Za-z_][A-Za-z0-9_-]*)`)
+	// element name at the start of a compound selector e.g. "ul" in "div > ul.nav"
+	cssTagRx = regexp.MustCompile(`(?:^|[\s>+~])([A-Za-z][A-Za-z0-9-]*)`)
+	// parts of a selector that don't matter for deciding if it's used
+	cssIgnoredRx = regexp.MustCompile(`\[[^\]]*\]|::?[A-Za-z-]+`)
+)
+
+func newCSSUsage() *cssUsage {
+	return &cssUsage{
+		words: map[string]bool{},
+		tags:  map[string]bool{},
+	}
+}
+
+func (u *cssUsage) addHTML(d []byte) {
+	for _, w := range htmlWordRx.FindAll(d, -1) {
+		u.words[string(w)] = true
+	}
+	for _, m := range htmlTagRx.FindAllSubmatch(d, -1) {
+		u.tags[strings.ToLower(string(m[1]))] = true
+	}
+}
+
+// collectCSSUsage returns usage of css in all html files in dir
+func collectCSSUsage(dir string) (*cssUsage, error) {
+	res := newCSSUsage()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".html" {
+			return nil
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		res.addHTML(d)
+		return nil
+	})
+	return res, err
+}
+
+// isSelectorUsed returns false if sel can't match anything in html.
+// When not sure, it returns true
+func (u *cssUsage) isSelectorUsed(sel string) bool {
+	sel = cssIgnoredRx.ReplaceAllString(sel, "")
+	for _, m := range cssClassOrIDRx.FindAllStringSubmatch(sel, -1) {
+		if !u.words[m[1]] {
+			return false
+		}
+	}
+	sel = cssClassOrIDRx.ReplaceAllString(sel, "")
+	for _, m := range cssTagRx.FindAllStringSubmatch(sel, -1) {
+		tag := strings.ToLower(m[1])
+		if !u.tags[tag] && tag != "html" && tag != "body" {
+			return false
+		}
+	}
+	return true
+}
+
+// purgeSelectors returns selectors from comma-separated list sel that
+// might be used
+func (u *cssUsage) purgeSelectors(sel string) string {
+	// commas inside :not(a, b) or strings would need real parsing
+	if strings.ContainsAny(sel, `()"'\`) {
+		return sel
+	}
+	var used []string
+	for _, s := range strings.Split(sel, ",") {
+		s = strings.TrimSpace(s)
+		if u.isSelectorUsed(s) {
+			used = append(used, s)
+		}
+	}
+	return strings.Join(used, ",\n")
+}
+
+// returns index of '}' that closes block starting at s[start], or -1
+func findBlockEnd(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// purgeCSS removes rules from css whose selectors don't match any
+// element in html described by u. It's conservative: at-rules other than
+// @media and @supports, and anything it doesn't understand is kept
+func purgeCSS(css string, u *cssUsage) string {
+	css = cssCommentRx.ReplaceAllString(css, "")
+	var buf bytes.Buffer
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			break
+		}
+		blockStart := strings.Index(css, "{")
+		if strings.HasPrefix(css, "@") {
+			// e.g. @import "foo.css";
+			if semi := strings.Index(css, ";"); semi != -1 && (blockStart == -1 || semi < blockStart) {
+				buf.WriteString(css[:semi+1] + "\n")
+				css = css[semi+1:]
+				continue
+			}
+		}
+		blockEnd := -1
+		if blockStart != -1 {
+			blockEnd = findBlockEnd(css, blockStart)
+		}
+		if blockEnd == -1 {
+			// malformed, keep the rest as is
+			buf.WriteString(css + "\n")
+			break
+		}
+		prelude := strings.TrimSpace(css[:blockStart])
+		body := css[blockStart+1 : blockEnd]
+		css = css[blockEnd+1:]
+
+		if strings.HasPrefix(prelude, "@media") || strings.HasPrefix(prelude, "@supports") {
+			inner := purgeCSS(body, u)
+			if inner != "" {
+				buf.WriteString(prelude + " {\n" + inner + "}\n\n")
+			}
+			continue
+		}
+		if strings.HasPrefix(prelude, "@") {
+			// e.g. @font-face, @keyframes
+			buf.WriteString(prelude + " {" + body + "}\n\n")
+			continue
+		}
+		sel := u.purgeSelectors(prelude)
+		if sel == "" {
+			continue
+		}
+		buf.WriteString(sel + " {" + body + "}\n\n")
+	}
+	return buf.String()
+}
+
+// purgeCSSFile re-writes css file at path in generated website in dir
+// with only the rules used by html files
+func purgeCSSFile(dir string, path string) error {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	u, err := collectCSSUsage(dir)
+	if err != nil {
+		return err
+	}
+	purged := purgeCSS(string(d), u)
+	lg("purged %s: %d => %d bytes\n", path, len(d), len(purged))
+	return ioutil.WriteFile(path, []byte(purged), 0644)
+}
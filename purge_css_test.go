@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeCSS(t *testing.T) {
+	css := `/* comment with .unused { } */
+@import url("fonts.css");
+body { margin: 0; }
+div.used { color: red; }
+div.unused { color: blue; }
+.used > span, .unused > span { color: green; }
+table td { padding: 1px; }
+#nav li:hover { color: gray; }
+p:not(.unused, .other) { margin: 0; }
+@media (max-width: 600px) {
+  .unused { display: none; }
+  .used { display: block; }
+}
+@media print {
+  .unused { display: none; }
+}
+@font-face { font-family: "Foo"; src: url(foo.woff); }
+`
+	u := newCSSUsage()
+	u.addHTML([]byte(`<html><body><div class="used"><span>x</span></div><UL id="nav"><li>a</li></UL><p>table</p>
+<script>document.body.classList.add("dynamic")</script></body></html>`))
+
+	got := purgeCSS(css, u)
+	exp := `@import url("fonts.css");
+body { margin: 0; }
+
+div.used { color: red; }
+
+.used > span { color: green; }
+
+#nav li:hover { color: gray; }
+
+p:not(.unused, .other) { margin: 0; }
+
+@media (max-width: 600px) {
+.used { display: block; }
+
+}
+
+@font-face { font-family: "Foo"; src: url(foo.woff); }
+
+`
+	assert.Equal(t, exp, got)
+
+	// classes added by javascript are kept
+	assert.True(t, u.isSelectorUsed(".dynamic"))
+	assert.False(t, u.isSelectorUsed("div.unused"))
+	assert.False(t, u.isSelectorUsed("table td"))
+}
+
+func TestPurgeCSSFile(t *testing.T) {
+	defer setTestOutDir(t)()
+	netlifyWriteFile("/css/main.css", []byte(".used { color: red; }\n.unused { color: blue; }\n"))
+	netlifyWriteFile("/article/foo/index.html", []byte(`<div class="used"></div>`))
+
+	path := filepath.Join(flgOutDir, "css", "main.css")
+	err := purgeCSSFile(flgOutDir, path)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ".used { color: red; }\n\n", string(d))
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -662,12 +665,116 @@ func netlifyAddArticleRedirects(store *Articles) {
 
 }
 
-// redirect /article/:id/* => /article/:id/pretty-title
-const netlifyRedirectsProlog = `/article/:id/*	/article/:id.html	200
+// redirectStubPath returns path of html file served for url path uri
+// e.g. "/old/" => "/old/index.html", "/old.html" => "/old.html"
+func redirectStubPath(uri string) string {
+	if strings.HasSuffix(uri, "/") {
+		return uri + "index.html"
+	}
+	if strings.HasSuffix(uri, ".html") || strings.HasSuffix(uri, ".htm") {
+		return uri
+	}
+	return uri + "/index.html"
+}
+
+// genRedirectStub returns html page that redirects to uri
+func genRedirectStub(uri string, canonicalURL string) []byte {
+	uri = html.EscapeString(uri)
+	canonicalURL = html.EscapeString(canonicalURL)
+	s := `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Redirecting...</title>
+  <link rel="canonical" href="%s">
+  <meta name="robots" content="noindex">
+  <meta http-equiv="refresh" content="0; url=%s">
+</head>
+<body>
+  <p>This page has moved to <a href="%s">%s</a>.</p>
+</body>
+</html>
 `
+	return []byte(fmt.Sprintf(s, canonicalURL, uri, uri, uri))
+}
+
+// netlifyWriteRedirectStubs writes html pages that redirect from old
+// urls of articles, set with "redirectfrom:" metadata, to articles.
+// Unlike _redirects they work on any static hosting
+func netlifyWriteRedirectStubs(store *Articles) {
+	for _, article := range store.getNotHidden() {
+		to := article.URL()
+		canonicalURL := articleCanonicalURL(article)
+		if canonicalURL == "" {
+			canonicalURL = to
+		}
+		d := genRedirectStub(to, canonicalURL)
+		for _, from := range article.RedirectFrom {
+			path := redirectStubPath(from)
+			// in incremental build the stub might be there from previous build
+			existing, err := ioutil.ReadFile(filepath.Join(flgOutDir, filepath.FromSlash(path)))
+			if !flgDryRun && err == nil && !bytes.Equal(existing, d) {
+				logWarn("Warning: not writing redirect from '%s' to '%s' (%s) because %s already exists\n", from, to, article.Title, path)
+				continue
+			}
+			netlifyWriteFile(path, d)
+		}
+	}
+}
+
+// redirects from -redirects-file, old url => new url
+var manualRedirects map[string]string
+
+// loadManualRedirects reads file with permanent redirects. Each line
+// is "<from> <to>" e.g. "/old.html /article/new/". Empty lines and lines
+// starting with # are ignored
+func loadManualRedirects(path string) (map[string]string, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]string{}
+	for i, l := range strings.Split(string(d), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		parts := strings.Fields(l)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: '%s' is not '<from> <to>'", path, i+1, l)
+		}
+		res[parts[0]] = parts[1]
+	}
+	return res, nil
+}
+
+// genRedirectsFile generates permanent redirects in _redirects format
+// from "redirectfrom:" metadata of articles and from manual redirects
+func genRedirectsFile(articles []*Article, manual map[string]string) []byte {
+	var buf bytes.Buffer
+	for _, article := range articles {
+		for _, from := range article.RedirectFrom {
+			// netlifyWriteRedirectStubs writes a file at from path and netlify
+			// ignores redirects from existing files unless they're forced with !
+			fmt.Fprintf(&buf, "%s\t%s\t%d!\n", from, article.URL(), 301)
+		}
+	}
+	var froms []string
+	for from := range manual {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		fmt.Fprintf(&buf, "%s\t%s\t%d\n", from, manual[from], 301)
+	}
+	return buf.Bytes()
+}
 
-func netlifyWriteRedirects() {
-	buf := bytes.NewBufferString(netlifyRedirectsProlog)
+// netlifyWriteRedirects writes _redirects file. Permanent redirects of
+// articles and from -redirects-file go first so that they take precedence
+func netlifyWriteRedirects(store *Articles) {
+	var buf bytes.Buffer
+	buf.Write(genRedirectsFile(store.getNotHidden(), manualRedirects))
 	for _, r := range netlifyRedirects {
 		s := fmt.Sprintf("%s\t%s\t%d\n", r.from, r.to, r.code)
 		buf.WriteString(s)
@@ -676,15 +783,15 @@ func netlifyWriteRedirects() {
 }
 
 // https://caddyserver.com/tutorial/caddyfile
-// redirect /article/:id/* => /article/:id/pretty-title
+// rewrite /article/:slug/* => /article/:slug/index.html
 var caddyProlog = `localhost:8080
-root netlify_static
+root %s
 errors stdout
 log stdout
 
 rewrite / {
 	r  ^/article/(.*)/.*$
-	to /article/{1}.html
+	to /article/{1}/index.html
 }
 
 `
@@ -728,7 +835,7 @@ func writeCaddyConfig() {
 	panicIfErr(err)
 	defer f.Close()
 
-	_, err = f.Write([]byte(caddyProlog))
+	_, err = f.Write([]byte(fmt.Sprintf(caddyProlog, flgOutDir)))
 	panicIfErr(err)
 	for _, r := range netlifyRedirects {
 		s := genCaddyRedir(r)
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectStubPath(t *testing.T) {
+	assert.Equal(t, "/old/index.html", redirectStubPath("/old/"))
+	assert.Equal(t, "/old/index.html", redirectStubPath("/old"))
+	assert.Equal(t, "/article/old.html", redirectStubPath("/article/old.html"))
+}
+
+func TestRedirectFromMeta(t *testing.T) {
+	defer setTestOutDir(t)()
+
+	page := mkTestPageWithBlocks(
+		mkTestBlock("b1", notionapi.BlockText, "Id: 5"),
+		mkTestBlock("b2", notionapi.BlockText, "RedirectFrom: /article/old-title.html"),
+		mkTestBlock("b3", notionapi.BlockText, "Article text"),
+	)
+	article := notionPageToArticle(nil, page)
+	assert.Equal(t, []string{"/article/old-title.html"}, article.RedirectFrom)
+
+	store := mkTestArticles(article)
+	netlifyWriteRedirectStubs(store)
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "article", "old-title.html"))
+	assert.NoError(t, err)
+	s := string(d)
+	to := article.URL()
+	assert.Contains(t, s, `<meta http-equiv="refresh" content="0; url=`+to+`">`)
+	assert.Contains(t, s, `<link rel="canonical" href="`+to+`">`)
+	assert.Contains(t, s, `<a href="`+to+`">`)
+
+	// redirect must be forced because netlify doesn't redirect from
+	// existing files, like the stub
+	netlifyWriteRedirects(store)
+	d, err = ioutil.ReadFile(filepath.Join(flgOutDir, "_redirects"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), "/article/old-title.html\t"+to+"\t301!\n")
+
+	msg := recoverPanicMsg(func() {
+		setRedirectFromMust(article, "/ok/, old-title.html")
+	})
+	assert.Contains(t, msg, "'old-title.html' in redirectfrom: metadata is not a path")
+}
+
+func TestGenRedirectsFile(t *testing.T) {
+	a1 := mkTestArticle("1", "Moved", "2019-01-02", statusNormal)
+	a1.RedirectFrom = []string{"/article/old.html", "/kb/old/"}
+	a2 := mkTestArticle("2", "Not moved", "2019-01-03", statusNormal)
+
+	dir, err := ioutil.TempDir("", "blog_redirects")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "redirects.txt")
+	err = ioutil.WriteFile(path, []byte("# moved to a separate site\n/software/  https://software.example.com/\n\n/about.html /resume.html\n"), 0644)
+	assert.NoError(t, err)
+	manual, err := loadManualRedirects(path)
+	assert.NoError(t, err)
+
+	s := string(genRedirectsFile([]*Article{a1, a2}, manual))
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	exp := []string{
+		"/article/old.html\t" + a1.URL() + "\t301!",
+		"/kb/old/\t" + a1.URL() + "\t301!",
+		"/about.html\t/resume.html\t301",
+		"/software/\thttps://software.example.com/\t301",
+	}
+	assert.Equal(t, exp, lines)
+
+	err = ioutil.WriteFile(path, []byte("/ok/ /new/\n/missing-target/\n"), 0644)
+	assert.NoError(t, err)
+	_, err = loadManualRedirects(path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ":2: '/missing-target/' is not '<from> <to>'")
+}
@@ -5,7 +5,7 @@ build()
 {
     echo "building"
     go build -o blog
-    ./blog -deploy
+    ./blog -deploy -base-url https://blog.kowalczyk.info
     ./netlifyctl -A $NETLIFY_TOKEN deploy || true
     cat netlifyctl-debug.log || true
 }
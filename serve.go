@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func init() {
+	// not known to mime package
+	mime.AddExtensionType(".webmanifest", "application/manifest+json")
+}
+
+// makeServeHandler returns handler that serves files from generated
+// website in dir. For directories it serves index.html
+func makeServeHandler(dir string) http.Handler {
+	fs := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verbose("serve: %s\n", r.URL.Path)
+		fs.ServeHTTP(w, r)
+	})
+}
+
+// startServe serves generated website in dir on a given port until
+// the process is interrupted. Unlike netlify, it doesn't apply _redirects
+func startServe(dir string, port int) {
+	httpSrv := &http.Server{
+		Addr:         fmt.Sprintf("127.0.0.1:%d", port),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		IdleTimeout:  120 * time.Second,
+		Handler:      makeServeHandler(dir),
+	}
+
+	go func() {
+		err := httpSrv.ListenAndServe()
+		// mute error caused by Shutdown()
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		panicIfErr(err)
+	}()
+	lg("Serving '%s' on http://%s\n", dir, httpSrv.Addr)
+	waitForSignal()
+}
+
+// waitForSignal blocks until the process is interrupted with ctrl-c
+func waitForSignal() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt /* SIGINT */, syscall.SIGTERM)
+	sig := <-c
+	lg("Got signal %s\n", sig)
+}
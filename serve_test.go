@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	defer setTestOutDir(t)()
+	netlifyWriteFile("/index.html", []byte("<html>index</html>"))
+	netlifyWriteFile("/article/foo/index.html", []byte("<html>foo</html>"))
+	netlifyWriteFile("/site.webmanifest", []byte("{}"))
+	assert.FileExists(t, filepath.Join(flgOutDir, "index.html"))
+
+	srv := httptest.NewServer(makeServeHandler(flgOutDir))
+	defer srv.Close()
+	get := func(uri string) (*http.Response, string) {
+		rsp, err := http.Get(srv.URL + uri)
+		assert.NoError(t, err)
+		defer rsp.Body.Close()
+		d, err := ioutil.ReadAll(rsp.Body)
+		assert.NoError(t, err)
+		return rsp, string(d)
+	}
+
+	rsp, s := get("/")
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", rsp.Header.Get("Content-Type"))
+	assert.Equal(t, "<html>index</html>", s)
+
+	rsp, s = get("/article/foo/")
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "<html>foo</html>", s)
+
+	rsp, _ = get("/site.webmanifest")
+	assert.Equal(t, "application/manifest+json", rsp.Header.Get("Content-Type"))
+
+	rsp, _ = get("/missing.html")
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+}
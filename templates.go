@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	"github.com/kjk/u"
 )
@@ -18,6 +18,7 @@ var (
 	tmplGenerateUniqueID = "generate-unique-id.tmpl.html"
 	tmplGoCookBook       = "go-cookbook.tmpl.html"
 	tmplChangelog        = "changelog.tmpl.html"
+	tmplCollection       = "collection.tmpl.html"
 	tmpl404              = "404.tmpl.html"
 	templateNames        = []string{
 		tmplMainPage,
@@ -27,15 +28,23 @@ var (
 		tmplGenerateUniqueID,
 		tmplGoCookBook,
 		tmplChangelog,
+		tmplCollection,
 		tmpl404,
 		"analytics.tmpl.html",
 		"page_navbar.tmpl.html",
+		"page_head.tmpl.html",
 	}
 	templatePaths []string
 	templates     *template.Template
 
-	// dirs to search when looking for templates
+	templateFuncs = template.FuncMap{
+		"renderNav": renderNav,
+	}
+
+	// dirs to search when looking for templates. Templates in "templates"
+	// over-ride the default templates in "www"
 	tmplDirs = []string{
+		"templates",
 		"www",
 		filepath.Join("www", "tmpl"),
 		filepath.Join("www", "tools"),
@@ -43,27 +52,75 @@ var (
 	}
 )
 
-func findTemplate(name string) string {
+// lookupTemplate returns path of template or "" if it doesn't exist
+func lookupTemplate(name string) string {
 	for _, dir := range tmplDirs {
 		path := filepath.Join(dir, name)
 		if u.FileExists(path) {
 			return path
 		}
 	}
-	panicIf(true, "didn't find tamplate %s in dirs %v", name, tmplDirs)
 	return ""
 }
 
+func findTemplate(name string) string {
+	path := lookupTemplate(name)
+	panicIf(path == "", "didn't find tamplate %s in dirs %v", name, tmplDirs)
+	return path
+}
+
+// findPageTemplates returns paths of templates in "templates" directory
+// that are not over-rides of default templates. Articles can use them
+// with "template:" metadata
+func findPageTemplates() []string {
+	paths, _ := filepath.Glob(filepath.Join(tmplDirs[0], "*.tmpl.html"))
+	var res []string
+	for _, path := range paths {
+		isDefault := false
+		for _, name := range templateNames {
+			if filepath.Base(path) == name {
+				isDefault = true
+			}
+		}
+		if !isDefault {
+			res = append(res, path)
+		}
+	}
+	return res
+}
+
 func loadTemplates() {
+	var err error
+	navItems, err = loadNavItems()
+	panicIfErr(err)
+	templatePaths = nil
 	for _, name := range templateNames {
 		path := findTemplate(name)
 		templatePaths = append(templatePaths, path)
 	}
-	templates = template.Must(template.ParseFiles(templatePaths...))
+	templatePaths = append(templatePaths, findPageTemplates()...)
+	templates = template.Must(template.New("").Funcs(templateFuncs).ParseFiles(templatePaths...))
+}
+
+// articleTemplate returns name of the template used to generate html
+// of the article
+func articleTemplate(article *Article) string {
+	if article.Template == "" {
+		return tmplArticle
+	}
+	if templates.Lookup(article.Template) == nil {
+		logWarn("Warning: template '%s' of article %s (%s) doesn't exist, using %s\n", article.Template, article.ID, article.Title, tmplArticle)
+		return tmplArticle
+	}
+	return article.Template
 }
 
 func netlifyExecTemplate(fileName string, templateName string, model interface{}) error {
 	path := netlifyPath(fileName)
+	if flgDryRun {
+		lg("dry-run: would write %s\n", path)
+		return nil
+	}
 	return execTemplateToFile(path, templateName, model)
 }
 
@@ -71,13 +128,17 @@ func execTemplateToFile(path string, templateName string, model interface{}) err
 	var buf bytes.Buffer
 	err := templates.ExecuteTemplate(&buf, templateName, model)
 	panicIfErr(err)
-	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	d := buf.Bytes()
+	if strings.HasSuffix(path, ".html") {
+		d = postProcessHTML(d)
+	}
+	_, err = writeFileIfChanged(path, d)
 	return err
 }
 
 func loadTemplate(name string) (*template.Template, error) {
 	path := filepath.Join("www", name)
-	return template.New(name).ParseFiles(path)
+	return template.New(name).Funcs(templateFuncs).ParseFiles(path)
 }
 
 func execTemplateToWriter(name string, data interface{}, w io.Writer) error {
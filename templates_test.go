@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplatesOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_templates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tmpl := `<title>{{.PageTitle}}</title><link rel="canonical" href="{{.CanonicalURL}}">{{.Article.HTMLBody}}`
+	err = ioutil.WriteFile(filepath.Join(dir, tmplArticle), []byte(tmpl), 0644)
+	assert.NoError(t, err)
+
+	prevDirs, prevBaseURL := tmplDirs, flgBaseURL
+	tmplDirs = append([]string{dir}, tmplDirs...)
+	flgBaseURL = "https://blog.kowalczyk.info"
+	defer func() {
+		tmplDirs, flgBaseURL = prevDirs, prevBaseURL
+		templates = nil
+	}()
+	loadTemplates()
+
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+	article.HTMLBody = template.HTML("<p>body</p>")
+	var buf bytes.Buffer
+	err = templates.ExecuteTemplate(&buf, tmplArticle, makeArticleModel(article))
+	assert.NoError(t, err)
+	exp := `<title>My title</title><link rel="canonical" href="https://blog.kowalczyk.info/article/my-title-1/"><p>body</p>`
+	assert.Equal(t, exp, buf.String())
+}
+
+func execTestArticleTemplate(t *testing.T, article *Article) string {
+	loadTemplates()
+	var buf bytes.Buffer
+	err := templates.ExecuteTemplate(&buf, tmplArticle, makeArticleModel(article))
+	assert.NoError(t, err)
+	return buf.String()
+}
+
+func TestArticleTemplate(t *testing.T) {
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+	article.HTMLBody = template.HTML("<p>body</p>")
+	article.ReadingTime = 5 * time.Minute
+	s := execTestArticleTemplate(t, article)
+	assert.Contains(t, s, "<title>My title</title>")
+	assert.Contains(t, s, "<p>body</p>")
+	assert.Contains(t, s, "5 min read")
+}
+
+func TestArticleTemplateSocialTags(t *testing.T) {
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+	article.Description = "my description"
+	article.HeaderImageURL = "https://blog.kowalczyk.info/img/header.png"
+	s := execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<meta property="og:title" content="My title">`)
+	assert.Contains(t, s, `<meta property="og:type" content="article" />`)
+	assert.Contains(t, s, `<meta property="og:description" content="my description">`)
+	assert.Contains(t, s, `<meta property="og:image" content="https://blog.kowalczyk.info/img/header.png">`)
+	assert.Contains(t, s, `<meta name="twitter:card" content="summary_large_image" />`)
+	assert.Contains(t, s, `<meta name="twitter:description" content="my description">`)
+
+	// no description so it's taken from the first paragraph
+	article = mkTestArticle("2", "Other title", "2019-01-01", statusNormal)
+	long := strings.Repeat("lorem ipsum ", 20)
+	article.page = mkTestPageWithBlocks(
+		mkTestBlock("h1", notionapi.BlockHeader, "Header"),
+		mkTestBlock("t1", notionapi.BlockText, long),
+	)
+	s = execTestArticleTemplate(t, article)
+	summary := strings.TrimSpace(long[:156]) + "…"
+	assert.Contains(t, s, `<meta property="og:description" content="`+summary+`">`)
+	assert.Contains(t, s, `<meta name="twitter:card" content="summary" />`)
+	assert.NotContains(t, s, `og:image`)
+}
+
+// setTestOutDir makes generated files go to a temporary directory
+func setTestOutDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "blog_out")
+	assert.NoError(t, err)
+	prevOutDir := flgOutDir
+	flgOutDir = dir
+	return func() {
+		flgOutDir = prevOutDir
+		os.RemoveAll(dir)
+	}
+}
+
+func TestOutDir(t *testing.T) {
+	defer setTestOutDir(t)()
+	netlifyWriteFile("/foo/bar.txt", []byte("bar"))
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "foo", "bar.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(d))
+
+	prevCacheDir := cacheDir
+	cacheDir, err = ioutil.TempDir("", "blog_cache")
+	assert.NoError(t, err)
+	defer func() {
+		os.RemoveAll(cacheDir)
+		cacheDir = prevCacheDir
+	}()
+	imgPath := filepath.Join(cacheDir, "img", "foo.png")
+	err = mkdirForFile(imgPath)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(imgPath, []byte("png"), 0644)
+	assert.NoError(t, err)
+	copyImages()
+	assert.FileExists(t, filepath.Join(flgOutDir, "img", "foo.png"))
+}
+
+func TestGen404Page(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+
+	gen404Page()
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "404.html"))
+	assert.NoError(t, err)
+	s := string(d)
+	assert.Contains(t, s, `<ul id="nav">`)
+	assert.Contains(t, s, "This page doesn't exist!")
+}
+
+func TestArticleTemplateCanonicalURL(t *testing.T) {
+	prevBaseURL := flgBaseURL
+	defer func() {
+		flgBaseURL = prevBaseURL
+	}()
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+
+	flgBaseURL = "https://example.com/"
+	s := execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<link rel="canonical" href="https://example.com/article/my-title-1/" />`)
+	assert.Contains(t, s, `<meta property="og:url" content="https://example.com/article/my-title-1/" />`)
+
+	flgBaseURL = ""
+	s = execTestArticleTemplate(t, article)
+	assert.NotContains(t, s, `rel="canonical"`)
+	assert.NotContains(t, s, `og:url`)
+}
+
+func TestArticleBreadcrumbs(t *testing.T) {
+	root := mkTestArticle(notionWebsiteStartPage, "Home page", "2019-01-01", statusNormal)
+	parent := mkTestArticle("1a2b3c4d5e6f", "Parent page", "2019-01-01", statusNormal)
+	parent.ParentID = root.ID
+	child := mkTestArticle("6f5e4d3c2b1a", "Child page", "2019-01-02", statusNormal)
+	child.ParentID = parent.ID
+	store := mkTestArticles(root, parent, child)
+	buildArticlesNavigation(store)
+
+	assert.Equal(t, 0, len(root.Paths))
+	assert.Equal(t, []URLPath{{URL: "/article/parent-page-1a2b3c4d/", Name: "Parent page"}}, child.Paths)
+	assert.Equal(t, "Home / Parent page", child.PathAsText())
+
+	s := execTestArticleTemplate(t, child)
+	assert.Contains(t, s, `<a href="/article/parent-page-1a2b3c4d/">Parent page</a> /`)
+	assert.Contains(t, s, "Child page")
+}
+
+func TestDateFormat(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	prevFormat := flgDateFormat
+	defer func() {
+		flgDateFormat = prevFormat
+	}()
+
+	article := mkTestArticle("1", "My title", "2019-03-07", statusNormal)
+	website := mkTestArticle(notionWebsiteStartPage, "Website", "2019-01-01", statusNormal)
+	website.inBlog = false
+	store := mkTestArticles(article, website)
+
+	flgDateFormat = ""
+	assert.Equal(t, "Mar 7, 2019", article.PublishedOnDisplay())
+	s := execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<time datetime="2019-03-07T00:00:00Z">Mar 7, 2019</time>`)
+
+	flgDateFormat = "2006-01-02"
+	s = execTestArticleTemplate(t, article)
+	assert.Contains(t, s, `<time datetime="2019-03-07T00:00:00Z">2019-03-07</time>`)
+
+	err := genIndex(store, nil)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), `<time datetime="2019-03-07T00:00:00Z" style="font-size:80%; color:gray">2019-03-07</time>`)
+}
+
+func TestNavItems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_nav")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prevNavPath := navPath
+	navPath = filepath.Join(dir, "nav.json")
+	defer func() {
+		navPath = prevNavPath
+		loadTemplates()
+	}()
+
+	// no nav.json means default links
+	loadTemplates()
+	s := execTestArticleTemplate(t, mkTestArticle("1", "My title", "2019-01-01", statusNormal))
+	assert.Contains(t, s, `<a href="/software/">Software</a>`)
+	assert.Contains(t, s, `<a href="/resume.html">About Me</a>`)
+
+	nav := `[{"label": "Home", "href": "/"}, {"label": "Notes & ideas", "href": "/notes/"}]`
+	err = ioutil.WriteFile(navPath, []byte(nav), 0644)
+	assert.NoError(t, err)
+	loadTemplates()
+	exp := `<ul id="nav">
+    <li>
+      <a href="/">Home</a>
+    </li>
+    <li>
+      <span style="color:#aaa">&bull;</span>
+    </li>
+    <li>
+      <a href="/notes/">Notes &amp; ideas</a>
+    </li>
+  </ul>`
+	assert.Equal(t, exp, string(renderNav()))
+	var buf bytes.Buffer
+	err = templates.ExecuteTemplate(&buf, tmplArchive, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), exp)
+	assert.NotContains(t, buf.String(), "About Me")
+
+	err = ioutil.WriteFile(navPath, []byte(`{"label": "Home"}`), 0644)
+	assert.NoError(t, err)
+	_, err = loadNavItems()
+	assert.Error(t, err)
+}
+
+func TestSharedLayout(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	article := mkTestArticle("1", "My title", "2019-03-07", statusNormal)
+	website := mkTestArticle(notionWebsiteStartPage, "Website", "2019-01-01", statusNormal)
+	website.inBlog = false
+	store := mkTestArticles(article, website)
+
+	articleHTML := execTestArticleTemplate(t, article)
+	err := genIndex(store, nil)
+	assert.NoError(t, err)
+	d, err := ioutil.ReadFile(filepath.Join(flgOutDir, "index.html"))
+	assert.NoError(t, err)
+	indexHTML := string(d)
+
+	// returns text between start and end, including them
+	region := func(s, start, end string) string {
+		idx := strings.Index(s, start)
+		assert.True(t, idx >= 0, "'%s' not found", start)
+		s = s[idx:]
+		idx = strings.Index(s, end)
+		assert.True(t, idx >= 0, "'%s' not found", end)
+		return s[:idx+len(end)]
+	}
+	head := region(articleHTML, "<head>", `<link href="/css/main.css" rel="stylesheet">`)
+	assert.Contains(t, head, `<meta charset="utf-8">`)
+	assert.Contains(t, head, `<link rel="icon" href="/favicon.ico">`)
+	assert.Equal(t, head, region(indexHTML, "<head>", `<link href="/css/main.css" rel="stylesheet">`))
+
+	nav := region(articleHTML, `<div id="tophdr">`, "</div>")
+	assert.Contains(t, nav, `<ul id="nav">`)
+	assert.Equal(t, nav, region(indexHTML, `<div id="tophdr">`, "</div>"))
+}
+
+func TestArticleTemplateOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_templates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tmpl := `<h1 class="landing">{{.PageTitle}}</h1>{{.Article.HTMLBody}}`
+	err = ioutil.WriteFile(filepath.Join(dir, "landing.tmpl.html"), []byte(tmpl), 0644)
+	assert.NoError(t, err)
+
+	prevDirs := tmplDirs
+	tmplDirs = append([]string{dir}, tmplDirs...)
+	defer func() {
+		tmplDirs = prevDirs
+		templates = nil
+	}()
+	defer setTestOutDir(t)()
+	loadTemplates()
+
+	article := mkTestArticle("1", "My title", "2019-01-01", statusNormal)
+	article.HTMLBody = template.HTML("<p>body</p>")
+	setTemplateMust(article, "landing")
+	assert.Equal(t, "landing.tmpl.html", article.Template)
+	path := netlifyArticlePath(article)
+	assert.True(t, netlifyWriteArticle(article, path, false))
+	d, err := ioutil.ReadFile(netlifyPath(path))
+	assert.NoError(t, err)
+	assert.Equal(t, `<h1 class="landing">My title</h1><p>body</p>`, string(d))
+
+	// missing template falls back to the default
+	setTemplateMust(article, "missing.tmpl.html")
+	assert.Equal(t, tmplArticle, articleTemplate(article))
+	assert.True(t, netlifyWriteArticle(article, path, false))
+	d, err = ioutil.ReadFile(netlifyPath(path))
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), "<title>My title</title>")
+
+	msg := recoverPanicMsg(func() {
+		setTemplateMust(article, "../www/article")
+	})
+	assert.Contains(t, msg, "is not a name of a template")
+}
@@ -11,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/yosssi/gohtml"
@@ -105,6 +107,44 @@ func charCanRepeat(c byte) bool {
 	return false
 }
 
+// slugs are easier to read without diacritics
+var slugReplacements = map[rune]string{
+	'ą': "a", 'ć': "c", 'ę': "e", 'ł': "l", 'ń': "n", 'ó': "o", 'ś': "s", 'ź': "z", 'ż': "z",
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i",
+	'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o", 'ø': "o",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u",
+	'ç': "c", 'ñ': "n", 'ý': "y", 'ß': "ss",
+}
+
+// slugify generates a readable part of url from a title. Letters and digits
+// are kept, everything else becomes a single '-'
+func slugify(title string) string {
+	var res []rune
+	prevDash := true
+	for _, r := range strings.ToLower(title) {
+		if s, ok := slugReplacements[r]; ok {
+			res = append(res, []rune(s)...)
+			prevDash = false
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			res = append(res, r)
+			prevDash = false
+			continue
+		}
+		if !prevDash {
+			res = append(res, '-')
+			prevDash = true
+		}
+	}
+	if len(res) > 64 {
+		res = res[:64]
+	}
+	return strings.Trim(string(res), "-")
+}
+
 // urlify generates safe url from tile by removing hazardous characters
 func urlify(title string) string {
 	s := strings.TrimSpace(title)
@@ -326,7 +366,24 @@ func mkdirForFile(filePath string) error {
 	return os.MkdirAll(dir, 0755)
 }
 
+// writeFileIfChanged writes data to path unless the file already has
+// this content, in which case it's not touched. Unchanged modification
+// time means less work for deploy tools that sync only changed files.
+// Returns true if the file was written
+func writeFileIfChanged(path string, data []byte) (bool, error) {
+	markGenerated(path)
+	if st, err := os.Stat(path); err == nil && st.Size() == int64(len(data)) {
+		existing, err := ioutil.ReadFile(path)
+		if err == nil && bytes.Equal(existing, data) {
+			return false, nil
+		}
+	}
+	err := ioutil.WriteFile(path, data, 0644)
+	return err == nil, err
+}
+
 func copyFile(dst string, src string) error {
+	markGenerated(dst)
 	err := mkdirForFile(dst)
 	if err != nil {
 		return err
@@ -382,6 +439,32 @@ func dirCopyRecur(dst string, src string, shouldSkipFile func(string) bool) (int
 	return nFilesCopied, nil
 }
 
+// copyStaticDir recursively copies files from src to dst, preserving
+// directory structure. It's not an error if src doesn't exist
+func copyStaticDir(src string, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+		err = copyFile(dstPath, path)
+		if err == nil {
+			verbose("Copied %s => %s\n", path, dstPath)
+		}
+		return err
+	})
+}
+
 func prettyHTML(d []byte) []byte {
 	// TODO: disable for now as it messes up inline by adding padding e.g.
 	// around bold elements
@@ -394,6 +477,116 @@ func prettyHTML(d []byte) []byte {
 	return []byte(s)
 }
 
+// content of those elements is copied as is by minifyHTML
+var minifyPreserveTags = []string{"pre", "textarea", "script", "style"}
+
+// hasPrefixFold is strings.HasPrefix ignoring ASCII case
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// indexFold is strings.Index ignoring ASCII case
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if hasPrefixFold(s[i:], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// minifyHTML removes comments and collapses runs of whitespace into a single
+// space, except inside elements like <pre> where whitespace matters
+func minifyHTML(d []byte) []byte {
+	s := string(d)
+	var res bytes.Buffer
+	res.Grow(len(s))
+	inSpace := false
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "<!--") && !strings.HasPrefix(s, "<!--[if") {
+			end := strings.Index(s, "-->")
+			if end == -1 {
+				// unterminated comment, keep the rest as is
+				res.WriteString(s)
+				break
+			}
+			s = s[end+3:]
+			continue
+		}
+		if s[0] == '<' {
+			preserved := false
+			for _, tag := range minifyPreserveTags {
+				if !hasPrefixFold(s[1:], tag) || len(s) <= len(tag)+1 {
+					continue
+				}
+				// must be a whole tag name e.g. not <preview>
+				c := s[len(tag)+1]
+				if c != '>' && c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != '/' {
+					continue
+				}
+				closeTag := "</" + tag
+				end := indexFold(s, closeTag)
+				if end == -1 {
+					end = len(s)
+				} else {
+					end += len(closeTag)
+				}
+				res.WriteString(s[:end])
+				s = s[end:]
+				inSpace = false
+				preserved = true
+				break
+			}
+			if preserved {
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if unicode.IsSpace(r) {
+			if !inSpace {
+				res.WriteByte(' ')
+			}
+			inSpace = true
+			continue
+		}
+		inSpace = false
+		res.WriteRune(r)
+	}
+	return res.Bytes()
+}
+
+// forEachConcurrently calls fn(i) for i from 0 to n-1, with at most
+// concurrency calls running at the same time
+func forEachConcurrently(n int, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	sem := make(chan bool, concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- true
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// postProcessHTML minifies generated html with -minify flag and
+// pretty-prints it otherwise
+func postProcessHTML(d []byte) []byte {
+	if flgMinify {
+		return minifyHTML(d)
+	}
+	return prettyHTML(d)
+}
+
 func fileExists(path string) bool {
 	st, err := os.Stat(path)
 	if err != nil {
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -135,3 +140,126 @@ func TestExtractNotionIDFromURL(t *testing.T) {
 		assert.Equal(t, test.exp, got)
 	}
 }
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		s    string
+		sExp string
+	}{
+		{"MicroConf videos", "microconf-videos"},
+		{"Laws of marketing #22 (resources) ", "laws-of-marketing-22-resources"},
+		{"  C++ -- the good parts!", "c-the-good-parts"},
+		{"Zażółć gęślą jaźń", "zazolc-gesla-jazn"},
+		{"Über Straße", "uber-strasse"},
+		{"日本語 タイトル", "日本語-タイトル"},
+		{"", ""},
+		{"?!... --- ###", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.sExp, slugify(test.s), "slugify(%q)", test.s)
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	code := "<pre class=\"chroma\"><code>func main() {\n    fmt.Println(\"hi\")\n}\n</code></pre>"
+	s := "<html>\n  <!-- comment -->\n  <body>\n    <p>Some   text\n   here</p>\n    " + code + "\n  <PRE>a\n  b</PRE>\n  </body>\n</html>\n"
+	got := string(minifyHTML([]byte(s)))
+	exp := "<html> <body> <p>Some text here</p> " + code + " <PRE>a\n  b</PRE> </body> </html> "
+	assert.Equal(t, exp, got)
+	assert.True(t, len(got) < len(s))
+
+	// <preview> is not <pre>
+	assert.Equal(t, "<preview> a </preview>", string(minifyHTML([]byte("<preview>  a  </preview>"))))
+	// conditional comments are kept
+	assert.Equal(t, "<!--[if IE]> x <![endif]-->", string(minifyHTML([]byte("<!--[if IE]>  x  <![endif]-->"))))
+	// unterminated comment is not dropped
+	assert.Equal(t, "<p>a</p> <!-- x\n  <p>b</p>", string(minifyHTML([]byte("<p>a</p>\n<!-- x\n  <p>b</p>"))))
+}
+
+func TestCopyStaticDir(t *testing.T) {
+	src, err := ioutil.TempDir("", "blog_static")
+	assert.NoError(t, err)
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "blog_static_out")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dst)
+
+	files := map[string]string{
+		"favicon.ico":               "ico",
+		"js/app.js":                 "js",
+		"fonts/sans/regular.woff2":  "font",
+		"img/icons/nested/logo.svg": "<svg/>",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	err = copyStaticDir(src, filepath.Join(dst, "static"))
+	assert.NoError(t, err)
+	for name, content := range files {
+		d, err := ioutil.ReadFile(filepath.Join(dst, "static", filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(d))
+	}
+
+	// missing source dir is skipped
+	err = copyStaticDir(filepath.Join(src, "missing"), dst)
+	assert.NoError(t, err)
+}
+
+func TestForEachConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	nRunning, maxRunning := 0, 0
+	done := make([]bool, 20)
+	forEachConcurrently(len(done), 3, func(i int) {
+		mu.Lock()
+		nRunning++
+		if nRunning > maxRunning {
+			maxRunning = nRunning
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		nRunning--
+		mu.Unlock()
+		done[i] = true
+	})
+	for i, isDone := range done {
+		assert.True(t, isDone, "%d not done", i)
+	}
+	assert.True(t, maxRunning <= 3)
+}
+
+func TestWriteFileIfChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blog_write")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index.html")
+
+	changed, err := writeFileIfChanged(path, []byte("<p>v1</p>"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	// the same content is not written
+	changed, err = writeFileIfChanged(path, []byte("<p>v1</p>"))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	st, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, st.ModTime().Equal(modTime))
+
+	// the same size but different content is written
+	changed, err = writeFileIfChanged(path, []byte("<p>v2</p>"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	d, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>v2</p>", string(d))
+	st, err = os.Stat(path)
+	assert.NoError(t, err)
+	assert.False(t, st.ModTime().Equal(modTime))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kjk/notionapi"
+)
+
+// rebuildChangedPages re-creates articles after cached pages with pageIDs
+// changed and re-generates html of those articles and index.html.
+// Returns new articles
+func rebuildChangedPages(c *notionapi.Client, store *Articles, pageIDs []string) *Articles {
+	idToPage := map[string]*notionapi.Page{}
+	for id, page := range store.idToPage {
+		idToPage[id] = page
+	}
+	var changed []string
+	for _, id := range pageIDs {
+		if _, ok := idToPage[id]; !ok {
+			// finding where a new page belongs requires full crawl
+			lg("watch: page %s is not part of the website, restart to include it\n", id)
+			continue
+		}
+		page := loadPageFromCache(cacheDir, id)
+		if page == nil {
+			continue
+		}
+		idToPage[id] = page
+		changed = append(changed, id)
+	}
+	if len(changed) == 0 {
+		return store
+	}
+
+	res := articlesFromPages(c, idToPage, store.idToParentID, store.idToRow)
+	for _, id := range changed {
+		article := res.idToArticle[id]
+		if article == nil || !article.shouldGenerate() {
+			continue
+		}
+		lg("watch: regenerating %s (%s)\n", article.URL(), article.Title)
+		netlifyWriteArticle(article, netlifyArticlePath(article), false)
+	}
+	err := genIndex(res, nil)
+	logIfError(err)
+	return res
+}
+
+// watchCache watches cacheDir and re-generates html of pages whose cache
+// files changed. Changes are handled after no more changes happened for
+// debounce time, so that multiple quick updates (e.g. re-downloading
+// a few pages) cause only one rebuild. Runs until stop is closed
+func watchCache(c *notionapi.Client, store *Articles, debounce time.Duration, stop <-chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	panicIfErr(err)
+	defer watcher.Close()
+	err = watcher.Add(cacheDir)
+	panicIfErr(err)
+	lg("watch: watching %s for changes\n", cacheDir)
+
+	pending := map[string]bool{}
+	// nil until there are pending changes
+	var rebuildTimer <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case err := <-watcher.Errors:
+			logWarn("Warning: watch: %s\n", err)
+		case ev := <-watcher.Events:
+			if ev.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			id := pageIDFromFileName(filepath.Base(ev.Name))
+			if id == "" {
+				continue
+			}
+			verbose("watch: page %s changed\n", id)
+			pending[id] = true
+			rebuildTimer = time.After(debounce)
+		case <-rebuildTimer:
+			var ids []string
+			for id := range pending {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			pending = map[string]bool{}
+			rebuildTimer = nil
+			store = rebuildChangedPages(c, store, ids)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/notionapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchCache(t *testing.T) {
+	loadTemplates()
+	defer setTestOutDir(t)()
+	dir, err := ioutil.TempDir("", "blog_cache")
+	assert.NoError(t, err)
+	prevCacheDir, prevRoots := cacheDir, rootPageIDs
+	cacheDir, rootPageIDs = dir, []string{mkTestPageID(1)}
+	defer func() {
+		cacheDir, rootPageIDs = prevCacheDir, prevRoots
+		os.RemoveAll(dir)
+	}()
+
+	mkPage := func(n int, text string) *notionapi.Page {
+		page := mkTestPageWithBlocks(mkTestBlock("t1", notionapi.BlockText, text))
+		page.ID = mkTestPageID(n)
+		page.Root.ID = page.ID
+		page.Root.Title = "Page"
+		return page
+	}
+	writeCache := func(page *notionapi.Page, modTime time.Time) {
+		d, err := json.Marshal(page)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, page.ID+".json")
+		assert.NoError(t, ioutil.WriteFile(path, d, 0644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	modTime := time.Now().Add(-time.Hour)
+	idToPage := map[string]*notionapi.Page{
+		mkTestPageID(1): mkPage(1, "website"),
+		mkTestPageID(2): mkPage(2, "original text"),
+	}
+	for _, page := range idToPage {
+		writeCache(page, modTime)
+	}
+	store := articlesFromPages(nil, idToPage, nil, nil)
+	article := store.idToArticle[mkTestPageID(2)]
+	htmlPath := netlifyPath(netlifyArticlePath(article))
+	netlifyWriteArticle(article, netlifyArticlePath(article), false)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watchCache(nil, store, 30*time.Millisecond, stop)
+		close(done)
+	}()
+	// let the watcher start watching the cache before modifying it
+	time.Sleep(50 * time.Millisecond)
+	writeCache(mkPage(2, "updated text"), time.Now())
+
+	var s string
+	for i := 0; i < 200 && !strings.Contains(s, "updated text"); i++ {
+		time.Sleep(10 * time.Millisecond)
+		d, _ := ioutil.ReadFile(htmlPath)
+		s = string(d)
+	}
+	close(stop)
+	<-done
+	assert.Contains(t, s, "updated text")
+	assert.NotContains(t, s, "original text")
+	assert.FileExists(t, filepath.Join(flgOutDir, "index.html"))
+}
@@ -0,0 +1,19 @@
+// adds handler to "Copy" buttons of code blocks that copies the code to clipboard
+document.addEventListener("DOMContentLoaded", function () {
+    var buttons = document.querySelectorAll("button.copy-code");
+    for (var i = 0; i < buttons.length; i++) {
+        buttons[i].addEventListener("click", function (ev) {
+            var btn = ev.currentTarget;
+            var pre = btn.parentNode.querySelector("pre");
+            if (!pre || !navigator.clipboard) {
+                return;
+            }
+            navigator.clipboard.writeText(pre.innerText).then(function () {
+                btn.textContent = "Copied";
+                setTimeout(function () {
+                    btn.textContent = "Copy";
+                }, 1500);
+            });
+        });
+    }
+});
@@ -136,7 +136,7 @@ func dumpAsLines(s string, idPrefix string) {
 	lines := toLines(s)
 	// dumpLines(lines)
 	s = genUL(lines, idPrefix) + "\n\n"
-	fmt.Printf(s)
+	fmt.Print(s)
 }
 
 func calcLines() {